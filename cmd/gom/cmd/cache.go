@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"text/tabwriter"
+	"time"
+
+	"github.com/damdo/gokrazy-machine/internal/oci"
+	"github.com/spf13/cobra"
+)
+
+// cacheCmd is gom cache.
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "manages the local cache of pulled OCI artifacts",
+	Long:  `manages the local cache of pulled OCI artifacts`,
+}
+
+// cacheLsCmd is gom cache ls.
+var cacheLsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "lists the cached OCI references and blobs",
+	Long:  `lists the cached OCI references and blobs`,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		return cacheImpl.ls(cmd.OutOrStdout())
+	},
+}
+
+// cachePruneCmd is gom cache prune.
+var cachePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "removes cached OCI blobs",
+	Long:  `removes cached OCI blobs, all of them or only those not used for a while`,
+	RunE: func(_ *cobra.Command, _ []string) error {
+		return cacheImpl.prune()
+	},
+}
+
+type cacheImplConfig struct {
+	olderThan time.Duration
+}
+
+var cacheImpl cacheImplConfig
+
+func init() {
+	cachePruneCmd.Flags().DurationVar(&cacheImpl.olderThan, "older-than", 0, "only remove blobs not used for this long"+
+		" (e.g. 720h), by default all blobs are removed")
+
+	cacheCmd.AddCommand(cacheLsCmd)
+	cacheCmd.AddCommand(cachePruneCmd)
+}
+
+func (r *cacheImplConfig) ls(out io.Writer) error {
+	cache, err := oci.DefaultCache()
+	if err != nil {
+		return err
+	}
+
+	refs, err := cache.Refs()
+	if err != nil {
+		return err
+	}
+
+	blobs, err := cache.Blobs()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "REFERENCE\tDIGEST")
+
+	for _, ref := range refs {
+		fmt.Fprintf(w, "%s\t%s\n", ref.Reference, ref.Descriptor.Digest)
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "BLOB\tSIZE\tLAST USED")
+
+	var total int64
+	for _, b := range blobs {
+		fmt.Fprintf(w, "%s\t%d\t%s\n", b.Digest, b.Size, b.LastUsed.Format(time.RFC3339))
+		total += b.Size
+	}
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("error printing cache: %w", err)
+	}
+
+	fmt.Fprintf(out, "\n%d blobs, %d bytes in %s\n", len(blobs), total, cache.Dir)
+
+	return nil
+}
+
+func (r *cacheImplConfig) prune() error {
+	cache, err := oci.DefaultCache()
+	if err != nil {
+		return err
+	}
+
+	pruned, err := cache.Prune(time.Now().Add(-r.olderThan))
+
+	var total int64
+	for _, b := range pruned {
+		total += b.Size
+	}
+
+	log.Printf("removed %d blobs, %d bytes from %s", len(pruned), total, cache.Dir)
+
+	return err
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/damdo/gokrazy-machine/internal/machine"
+	"github.com/damdo/gokrazy-machine/internal/qmp"
+	"github.com/spf13/cobra"
+)
+
+const ctlPause, ctlResume, ctlReset, ctlPowerdown, ctlStatus = "pause", "resume", "reset", "powerdown", "status"
+
+// ctlCmd is gom ctl.
+var ctlCmd = &cobra.Command{
+	Use:   "ctl <name> " + strings.Join(ctlCommands(), "|"),
+	Short: "controls a running gokrazy machine",
+	Long: `controls a running gokrazy machine through its QMP socket: ` +
+		`pause and resume its vcpus, reset it, ask the guest to power down, or print its run status`,
+	Args:      cobra.ExactArgs(2),
+	ValidArgs: ctlCommands(),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return ctlImpl.ctl(cmd.Context(), args[0], args[1], cmd.OutOrStdout())
+	},
+}
+
+type ctlImplConfig struct {
+	timeout time.Duration
+}
+
+var ctlImpl ctlImplConfig
+
+var (
+	errUnsupportedCtlCommand = errors.New("error unsupported ctl command")
+	errMachineNotRunning     = errors.New("error machine is not running")
+)
+
+func init() {
+	ctlCmd.Flags().DurationVar(&ctlImpl.timeout, "timeout", 10*time.Second, "timeout of the command")
+}
+
+func ctlCommands() []string {
+	return []string{ctlPause, ctlResume, ctlReset, ctlPowerdown, ctlStatus}
+}
+
+func (r *ctlImplConfig) ctl(ctx context.Context, name, command string, out io.Writer) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	c, err := dialMachine(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	switch command {
+	case ctlPause:
+		err = c.Stop(ctx)
+	case ctlResume:
+		err = c.Cont(ctx)
+	case ctlReset:
+		err = c.SystemReset(ctx)
+	case ctlPowerdown:
+		err = c.SystemPowerdown(ctx)
+	case ctlStatus:
+		var s qmp.Status
+
+		s, err = c.QueryStatus(ctx)
+		if err == nil {
+			fmt.Fprintln(out, s.Status)
+		}
+	default:
+		return fmt.Errorf("%w: %s, expected one of: %s", errUnsupportedCtlCommand, command, strings.Join(ctlCommands(), ", "))
+	}
+
+	if err != nil {
+		return fmt.Errorf("error running %s on machine %s: %w", command, name, err)
+	}
+
+	return nil
+}
+
+// dialMachine connects to the QMP socket of the running machine with the name.
+func dialMachine(ctx context.Context, name string) (*qmp.Client, error) {
+	if _, err := machine.Lookup(name); err != nil {
+		return nil, err
+	}
+
+	c, err := qmp.Dial(ctx, machine.QMPSocket(name))
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, syscall.ECONNREFUSED) {
+		return nil, fmt.Errorf("%w: %s", errMachineNotRunning, name)
+	}
+
+	return c, err
+}
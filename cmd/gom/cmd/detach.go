@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/damdo/gokrazy-machine/internal/machine"
+)
+
+// envDetachedName is the environment variable naming the machine run by a detached gom process.
+const envDetachedName = "GOM_DETACHED_NAME"
+
+// followInterval is the interval logs are polled at when followed.
+const followInterval = 200 * time.Millisecond
+
+var (
+	errDetachPasswordStdin = errors.New("error `--oci.password-stdin` is not supported with `--detach`")
+	errDetachedExited      = errors.New("error detached machine exited before starting")
+	errDetachedNotReady    = errors.New("error detached machine exited before being ready")
+)
+
+// spawnDetached runs gom play again in the background, in its own session, for the machine with the name.
+// The output of the detached gom is logged to the machine directory, and relayed to stderr until qemu is started,
+// or until the machine is ready with --wait-ready.
+// Once started, it prints the name of the machine to out and its summary to errOut,
+// or the summary to out with the json output.
+func spawnDetached(ctx context.Context, name string, out, errOut io.Writer) error {
+	if playImpl.ociPassStdin {
+		return errDetachPasswordStdin
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("error getting gom executable: %w", err)
+	}
+
+	if err := machine.Create(name); err != nil {
+		return err
+	}
+
+	logFile, err := os.Create(machine.Log(name))
+	if err != nil {
+		return fmt.Errorf("error creating machine log: %w", err)
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Env = append(os.Environ(), envDetachedName+"="+name)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("error starting detached gom: %w", err)
+	}
+
+	exited := make(chan struct{})
+	go func() {
+		_ = cmd.Wait()
+		close(exited)
+	}()
+
+	isExited := func() bool {
+		select {
+		case <-exited:
+			return true
+		default:
+			return false
+		}
+	}
+
+	err = follow(ctx, machine.Log(name), errOut, func() bool {
+		if isExited() {
+			return true
+		}
+
+		s, err := machine.ReadState(name)
+		if playImpl.waitReady {
+			return err == nil && s.Ready
+		}
+
+		return err == nil && !s.Starting()
+	})
+	if err != nil {
+		return err
+	}
+
+	if isExited() {
+		if err := machine.Remove(name); err != nil {
+			log.Println(err)
+		}
+
+		if playImpl.waitReady {
+			return fmt.Errorf("%w: %s", errDetachedNotReady, name)
+		}
+
+		return fmt.Errorf("%w: %s", errDetachedExited, name)
+	}
+
+	if ctx.Err() != nil {
+		log.Printf("machine %s keeps starting in the background", name)
+
+		if playImpl.output != outputJSON {
+			fmt.Fprintln(out, name)
+		}
+
+		return nil
+	}
+
+	s, err := machine.ReadState(name)
+	if err != nil {
+		return err
+	}
+
+	if playImpl.output == outputJSON {
+		return printMachineInfo(out, s.Info(), outputJSON)
+	}
+
+	fmt.Fprintln(out, name)
+
+	return printMachineInfo(errOut, s.Info(), outputText)
+}
+
+// follow copies the content of the file at path to w as it grows, until done reports true
+// or the context is canceled.
+func follow(ctx context.Context, path string, w io.Writer, done func() bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error opening log: %w", err)
+	}
+	defer f.Close()
+
+	for {
+		n, err := io.Copy(w, f)
+		if err != nil {
+			return fmt.Errorf("error reading log: %w", err)
+		}
+
+		if n > 0 {
+			continue
+		}
+
+		if done() {
+			// Copy what was written in the meantime.
+			if _, err := io.Copy(w, f); err != nil {
+				return fmt.Errorf("error reading log: %w", err)
+			}
+
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(followInterval):
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+
+	"github.com/damdo/gokrazy-machine/internal/disk"
+	"github.com/damdo/gokrazy-machine/internal/gaf"
+	"github.com/spf13/cobra"
+)
+
+// diskCmd is gom disk.
+var diskCmd = &cobra.Command{
+	Use:   "disk",
+	Short: "manipulates gokrazy disk images",
+	Long:  `manipulates gokrazy disk images`,
+}
+
+// diskSplitCmd is gom disk split.
+var diskSplitCmd = &cobra.Command{
+	Use:   "split",
+	Short: "splits a full disk image into mbr, boot, root and sbom parts",
+	Long:  `splits a full disk image into mbr, boot, root and sbom parts`,
+	RunE: func(_ *cobra.Command, _ []string) error {
+		return diskSplitImpl.split()
+	},
+}
+
+type diskSplitImplConfig struct {
+	full      string
+	outputDir string
+}
+
+var diskSplitImpl diskSplitImplConfig
+
+var errMissingFull = errors.New("missing full disk image, please specify `--full`")
+
+var outputDirPermission os.FileMode = 0755
+
+func init() {
+	diskSplitCmd.Flags().StringVar(&diskSplitImpl.full, "full", "", "path to the img of the full drive file to split")
+	diskSplitCmd.Flags().StringVar(&diskSplitImpl.outputDir, "output-dir", ".", "path to the directory to write the parts to")
+
+	diskCmd.AddCommand(diskSplitCmd)
+}
+
+func (r *diskSplitImplConfig) split() error {
+	if r.full == "" {
+		return errMissingFull
+	}
+
+	if err := os.MkdirAll(r.outputDir, outputDirPermission); err != nil {
+		return fmt.Errorf("error creating output directory: %w", err)
+	}
+
+	mbrPath := path.Join(r.outputDir, gaf.MBR)
+	bootPath := path.Join(r.outputDir, gaf.Boot)
+	rootPath := path.Join(r.outputDir, gaf.Root)
+	sbomPath := path.Join(r.outputDir, gaf.SBOM)
+
+	log.Printf("splitting full disk image %s to disk part images: %s, %s, %s", r.full, mbrPath, bootPath, rootPath)
+
+	hasSBOM, err := disk.FullToParts(r.full, mbrPath, bootPath, rootPath, sbomPath)
+	if err != nil {
+		return fmt.Errorf("unable to split full disk img %s: %w", r.full, err)
+	}
+
+	if hasSBOM {
+		log.Printf("wrote sbom %s", sbomPath)
+	} else {
+		log.Printf("no sbom found in root partition at %s, skipping it", disk.SBOMPath)
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/damdo/gokrazy-machine/internal/oci"
+	"github.com/spf13/cobra"
+)
+
+// exportCmd is gom export.
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "exports an OCI artifact to an oci image layout",
+	Long: `exports an OCI artifact from a registry to an oci image layout directory or tar archive, ` +
+		`that can be run with gom play --oci-layout or --oci-archive without access to the registry`,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		return exportImpl.export(cmd.Context(), cmd.OutOrStdout())
+	},
+}
+
+type exportImplConfig struct {
+	oci           string
+	outputDir     string
+	outputArchive string
+	ociUser       string
+	ociPassword   string
+	ociPassStdin  bool
+	ociPlainHTTP  bool
+}
+
+var exportImpl exportImplConfig
+
+var (
+	errMissingExportReference = errors.New("missing oci reference, please specify `--oci`")
+	errMissingExportOutput    = errors.New("missing output, please specify either: " +
+		"`--output-dir` or `--output-archive`")
+)
+
+func init() {
+	exportCmd.Flags().StringVar(&exportImpl.oci, "oci", "", "the oci artifact reference to export "+
+		"(e.g. docker.io/damdo/gokrazy:sample-amd64)")
+	exportCmd.Flags().StringVar(&exportImpl.outputDir, "output-dir", "", "path to the oci image layout directory to export to")
+	exportCmd.Flags().StringVar(&exportImpl.outputArchive, "output-archive", "", "path to the oci image layout tar archive to export to")
+	exportCmd.Flags().StringVar(&exportImpl.ociUser, "oci.user", "", "the username for the OCI registry")
+	exportCmd.Flags().StringVar(&exportImpl.ociPassword, "oci.password", "", "the password for the OCI registry")
+	exportCmd.Flags().BoolVar(&exportImpl.ociPassStdin, "oci.password-stdin", false, "read the password for the OCI registry from stdin")
+	exportCmd.Flags().BoolVar(&exportImpl.ociPlainHTTP, "oci.plainHTTP", false, "allow the use of plain HTTP for OCI registry")
+}
+
+func (r *exportImplConfig) export(ctx context.Context, out io.Writer) error {
+	if r.oci == "" {
+		return errMissingExportReference
+	}
+
+	opts, err := ociPullOptions(r.ociUser, r.ociPassword, r.ociPassStdin, r.ociPlainHTTP, false, true)
+	if err != nil {
+		return err
+	}
+
+	var dest string
+
+	switch {
+	case r.outputDir != "":
+		dest = r.outputDir
+		_, err = oci.ExportImageLayout(ctx, r.oci, r.outputDir, opts)
+
+	case r.outputArchive != "":
+		dest = r.outputArchive
+		_, err = oci.ExportImageArchive(ctx, r.oci, r.outputArchive, opts)
+
+	default:
+		return errMissingExportOutput
+	}
+
+	if err != nil {
+		return fmt.Errorf("error exporting oci artifact: %w", err)
+	}
+
+	log.Printf("exported %s to %s", r.oci, dest)
+
+	ref, err := oci.ParseReference(r.oci)
+	if err != nil {
+		return err
+	}
+
+	// Print the reference to pass to --oci-layout or --oci-archive, digests follow an @.
+	if _, err := ref.Digest(); err == nil {
+		fmt.Fprintf(out, "%s@%s\n", dest, ref.Reference)
+	} else {
+		fmt.Fprintf(out, "%s:%s\n", dest, ref.Reference)
+	}
+
+	return nil
+}
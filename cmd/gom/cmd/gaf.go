@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+
+	"github.com/damdo/gokrazy-machine/internal/disk"
+	"github.com/damdo/gokrazy-machine/internal/gaf"
+	"github.com/spf13/cobra"
+)
+
+// gafCmd is gom gaf.
+var gafCmd = &cobra.Command{
+	Use:   "gaf",
+	Short: "manipulates .gaf (gokrazy archive format) files",
+	Long:  `manipulates .gaf (gokrazy archive format) files`,
+}
+
+// gafPackCmd is gom gaf pack.
+var gafPackCmd = &cobra.Command{
+	Use:   "pack",
+	Short: "creates a .gaf from disk parts or a full disk image",
+	Long:  `creates a .gaf from disk parts (mbr, boot, root, sbom) or a full disk image`,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		return gafPackImpl.pack(cmd.Context())
+	},
+}
+
+type gafPackImplConfig struct {
+	full   string
+	mbr    string
+	boot   string
+	root   string
+	sbom   string
+	output string
+}
+
+var gafPackImpl gafPackImplConfig
+
+var (
+	errMissingGafOutput  = errors.New("missing output path, please specify `--output`")
+	errMissingGafSources = errors.New("missing sources, please specify either: " +
+		"`--full` or (`--mbr` + `--boot` + `--root` + `--sbom`)")
+	errMissingSBOM = errors.New("no sbom found in the full disk image, please specify `--sbom`")
+)
+
+func init() {
+	gafPackCmd.Flags().StringVar(&gafPackImpl.full, "full", "", "path to the img of the full drive file")
+	gafPackCmd.Flags().StringVar(&gafPackImpl.mbr, "mbr", "", "path to the mbr part of the drive")
+	gafPackCmd.Flags().StringVar(&gafPackImpl.boot, "boot", "", "path to the boot part of the drive")
+	gafPackCmd.Flags().StringVar(&gafPackImpl.root, "root", "", "path to the root part of the drive")
+	gafPackCmd.Flags().StringVar(&gafPackImpl.sbom, "sbom", "", "path to the sbom.json of the drive")
+	gafPackCmd.Flags().StringVar(&gafPackImpl.output, "output", "", "path to the .gaf file to create")
+
+	gafCmd.AddCommand(gafPackCmd)
+}
+
+func (r *gafPackImplConfig) pack(ctx context.Context) error {
+	if r.output == "" {
+		return errMissingGafOutput
+	}
+
+	mbrPath, bootPath, rootPath, sbomPath := r.mbr, r.boot, r.root, r.sbom
+
+	switch {
+	case r.full != "":
+		tmpDir, err := os.MkdirTemp("", "gom")
+		if err != nil {
+			return fmt.Errorf("error creating temporary directory: %w", err)
+		}
+		defer os.RemoveAll(tmpDir)
+
+		mbrPath = path.Join(tmpDir, gaf.MBR)
+		bootPath = path.Join(tmpDir, gaf.Boot)
+		rootPath = path.Join(tmpDir, gaf.Root)
+		if sbomPath == "" {
+			sbomPath = path.Join(tmpDir, gaf.SBOM)
+		}
+
+		log.Printf("splitting full disk image %s", r.full)
+
+		hasSBOM, err := disk.FullToParts(r.full, mbrPath, bootPath, rootPath, path.Join(tmpDir, gaf.SBOM))
+		if err != nil {
+			return fmt.Errorf("unable to split full disk img %s: %w", r.full, err)
+		}
+
+		if !hasSBOM && r.sbom == "" {
+			return errMissingSBOM
+		}
+
+	case mbrPath == "" || bootPath == "" || rootPath == "" || sbomPath == "":
+		return errMissingGafSources
+	}
+
+	readers := gaf.Readers{}
+	for _, src := range []struct {
+		path   string
+		reader *io.Reader
+	}{
+		{mbrPath, &readers.MBR},
+		{bootPath, &readers.Boot},
+		{rootPath, &readers.Root},
+		{sbomPath, &readers.SBOM},
+	} {
+		f, err := os.Open(src.path)
+		if err != nil {
+			return fmt.Errorf("unable to open gaf source %s: %w", src.path, err)
+		}
+		defer f.Close()
+
+		*src.reader = f
+	}
+
+	out, err := os.Create(r.output)
+	if err != nil {
+		return fmt.Errorf("unable to create gaf file %s: %w", r.output, err)
+	}
+	defer out.Close()
+
+	log.Printf("packing disk part images: %s, %s, %s, %s into %s", mbrPath, bootPath, rootPath, sbomPath, r.output)
+
+	if err := gaf.Create(ctx, out, readers); err != nil {
+		return fmt.Errorf("unable to create gaf file %s: %w", r.output, err)
+	}
+
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("unable to close gaf file %s: %w", r.output, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/damdo/gokrazy-machine/internal/disk"
+	"github.com/damdo/gokrazy-machine/internal/gaf"
+	"github.com/damdo/gokrazy-machine/internal/oci"
+	"github.com/spf13/cobra"
+)
+
+// inspectCmd is gom inspect.
+var inspectCmd = &cobra.Command{
+	Use:   "inspect",
+	Short: "prints information about a gokrazy image",
+	Long: `prints the partition table, hostname, kernel version, binaries and sbom ` +
+		`of a gokrazy image from any of the supported sources`,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		return inspectImpl.inspect(cmd.Context(), cmd.OutOrStdout())
+	},
+}
+
+type inspectImplConfig struct {
+	full         string
+	gaf          string
+	oci          string
+	parts        string
+	arch         string
+	ociUser      string
+	ociPassword  string
+	ociPassStdin bool
+	ociPlainHTTP bool
+	ociNoCache   bool
+	offline      bool
+	json         bool
+}
+
+var inspectImpl inspectImplConfig
+
+var errMissingInspectSource = errors.New("missing source, please specify either: " +
+	"`--full` or `--gaf` or `--oci` or `--parts`")
+
+func init() {
+	inspectCmd.Flags().StringVar(&inspectImpl.full, "full", "", "path to the img of the drive file")
+	inspectCmd.Flags().StringVar(&inspectImpl.gaf, "gaf", "", "path to the .gaf (gokrazy archive format) of the drive file")
+	inspectCmd.Flags().StringVar(&inspectImpl.oci, "oci", "", "path to the remote oci artifact reference "+
+		"(e.g. docker.io/damdo/gokrazy:sample-amd64)")
+	inspectCmd.Flags().StringVar(&inspectImpl.parts, "parts", "", "path to a directory holding the "+
+		gaf.MBR+", "+gaf.Boot+", "+gaf.Root+" and optionally "+gaf.SBOM+" parts of the drive")
+	inspectCmd.Flags().StringVar(&inspectImpl.arch, "arch", "", "the architecture of the image to inspect "+
+		"when the --oci reference is a multi-arch image index")
+	inspectCmd.Flags().StringVar(&inspectImpl.ociUser, "oci.user", "", "the username for the OCI registry")
+	inspectCmd.Flags().StringVar(&inspectImpl.ociPassword, "oci.password", "", "the password for the OCI registry")
+	inspectCmd.Flags().BoolVar(&inspectImpl.ociPassStdin, "oci.password-stdin", false, "read the password for the OCI registry from stdin")
+	inspectCmd.Flags().BoolVar(&inspectImpl.ociPlainHTTP, "oci.plainHTTP", false, "allow the use of plain HTTP for OCI registry")
+	inspectCmd.Flags().BoolVar(&inspectImpl.ociNoCache, "oci.no-cache", false, "do not use the local cache of pulled OCI artifacts")
+	inspectCmd.Flags().BoolVar(&inspectImpl.offline, "offline", false, "inspect the --oci reference from the local cache"+
+		" without contacting the OCI registry")
+	inspectCmd.Flags().BoolVar(&inspectImpl.json, "json", false, "print the information as JSON")
+}
+
+func (r *inspectImplConfig) inspect(ctx context.Context, out io.Writer) error {
+	baseDir, err := os.MkdirTemp("", "gom")
+	if err != nil {
+		return fmt.Errorf("error creating temporary directory: %w", err)
+	}
+	defer os.RemoveAll(baseDir)
+
+	diskFile := path.Join(baseDir, "disk.img")
+
+	var sbom []byte
+
+	switch {
+	case r.full != "":
+		diskFile = r.full
+
+	case r.gaf != "" || r.oci != "":
+		gafPath := r.gaf
+		partsDir := ""
+
+		if r.oci != "" {
+			opts, err := ociPullOptions(r.ociUser, r.ociPassword, r.ociPassStdin, r.ociPlainHTTP, r.offline, r.ociNoCache)
+			if err != nil {
+				return err
+			}
+
+			opts.Arch = r.arch
+
+			layout, err := oci.Pull(ctx, r.oci, baseDir, opts)
+			if err != nil {
+				return fmt.Errorf("error pulling remote oci artifacts: %w", err)
+			}
+
+			gafPath = path.Join(baseDir, oci.LayerGaf)
+			if layout == oci.LayoutParts {
+				partsDir = baseDir
+			}
+		}
+
+		if partsDir != "" {
+			sbom, err = partsToFull(partsDir, diskFile, disk.DefaultDiskSize)
+		} else {
+			sbom, err = gafToFull(ctx, gafPath, diskFile, disk.DefaultDiskSize)
+		}
+
+		if err != nil {
+			return err
+		}
+
+	case r.parts != "":
+		sbom, err = partsToFull(r.parts, diskFile, disk.DefaultDiskSize)
+		if err != nil {
+			return err
+		}
+
+	default:
+		return errMissingInspectSource
+	}
+
+	info, err := disk.Inspect(diskFile)
+	if err != nil {
+		return fmt.Errorf("unable to inspect disk img: %w", err)
+	}
+
+	if len(sbom) > 0 {
+		if !json.Valid(sbom) {
+			log.Printf("ignoring sbom, it is not valid JSON")
+		} else {
+			info.SBOM = sbom
+		}
+	}
+
+	if r.json {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+
+		if err := enc.Encode(info); err != nil {
+			return fmt.Errorf("error encoding information: %w", err)
+		}
+
+		return nil
+	}
+
+	return printInfo(out, info)
+}
+
+func printInfo(out io.Writer, info disk.Info) error {
+	tableType := "MBR"
+	if info.PartitionTable.GPT {
+		tableType = "GPT"
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(w, "hostname:\t%s\n", info.Hostname)
+	fmt.Fprintf(w, "kernel version:\t%s\n", info.KernelVersion)
+	fmt.Fprintf(w, "active root:\tpartition %d\n", info.ActiveRoot)
+	fmt.Fprintf(w, "partition table:\t%s (disk id %s)\n", tableType, info.PartitionTable.DiskID)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "NUMBER\tOFFSET\tSIZE\tTYPE\tPARTUUID\tNAME")
+
+	for _, p := range info.PartitionTable.Partitions {
+		fmt.Fprintf(w, "%d\t%d\t%d\t%s\t%s\t%s\n", p.Number, p.Offset, p.Size, p.Type, p.UUID, p.Name)
+	}
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("error printing information: %w", err)
+	}
+
+	fmt.Fprintln(out, "\nbinaries (/user):")
+	for _, b := range info.Binaries {
+		fmt.Fprintf(out, "  %s\n", b)
+	}
+
+	if len(info.SBOM) > 0 {
+		var sbom bytes.Buffer
+		if err := json.Indent(&sbom, info.SBOM, "  ", "  "); err != nil {
+			return fmt.Errorf("error printing sbom: %w", err)
+		}
+
+		fmt.Fprintf(out, "\nsbom:\n  %s\n", strings.TrimSpace(sbom.String()))
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"context"
+	"io"
+
+	"github.com/damdo/gokrazy-machine/internal/machine"
+	"github.com/spf13/cobra"
+)
+
+// logsCmd is gom logs.
+var logsCmd = &cobra.Command{
+	Use:   "logs <name>",
+	Short: "prints the serial console log of a running gokrazy machine",
+	Long:  `prints the serial console log of a running gokrazy machine, following it with -f`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return logsImpl.logs(cmd.Context(), args[0], cmd.OutOrStdout())
+	},
+}
+
+type logsImplConfig struct {
+	follow bool
+}
+
+var logsImpl logsImplConfig
+
+func init() {
+	logsCmd.Flags().BoolVarP(&logsImpl.follow, "follow", "f", false, "follow the log until the machine stops")
+}
+
+func (r *logsImplConfig) logs(ctx context.Context, name string, out io.Writer) error {
+	s, err := machine.Lookup(name)
+	if err != nil {
+		return err
+	}
+
+	serialLog := s.SerialLog
+	if serialLog == "" {
+		serialLog = machine.SerialLog(name)
+	}
+
+	return follow(ctx, serialLog, out, func() bool {
+		if !r.follow {
+			return true
+		}
+
+		s, err := machine.ReadState(name)
+
+		return err != nil || !s.Alive()
+	})
+}
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/damdo/gokrazy-machine/internal/oci"
+)
+
+var (
+	errPasswordStdinFlags = errors.New("error `--oci.password` and `--oci.password-stdin` are mutually exclusive")
+	errRequireSignature   = errors.New("error `--require-signature` requires `--signature-key`")
+)
+
+// ociPassword returns the password for the OCI registry,
+// reading it from the first line of stdin if passwordStdin is set.
+func ociPassword(password string, passwordStdin bool) (string, error) {
+	if !passwordStdin {
+		return password, nil
+	}
+
+	if password != "" {
+		return "", errPasswordStdinFlags
+	}
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("error reading password from stdin: %w", err)
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// ociPullOptions returns the oci.PullOptions for the OCI flags of a command.
+func ociPullOptions(user, password string, passwordStdin, plainHTTP, offline, noCache bool) (oci.PullOptions, error) {
+	password, err := ociPassword(password, passwordStdin)
+	if err != nil {
+		return oci.PullOptions{}, err
+	}
+
+	opts := oci.PullOptions{
+		Username:  user,
+		Password:  password,
+		PlainHTTP: plainHTTP,
+		Offline:   offline,
+		Progress:  renderProgress(os.Stderr),
+	}
+
+	if noCache {
+		if offline {
+			return oci.PullOptions{}, fmt.Errorf("error %w, drop `--oci.no-cache`", oci.ErrOfflineNoCache)
+		}
+
+		return opts, nil
+	}
+
+	cache, err := oci.DefaultCache()
+	if err != nil {
+		return oci.PullOptions{}, err
+	}
+
+	opts.Cache = cache
+
+	return opts, nil
+}
+
+// ociSignatureOptions sets the signature verification options of opts
+// for the public key at keyPath, if any.
+func ociSignatureOptions(opts *oci.PullOptions, keyPath string, require bool) error {
+	if keyPath == "" {
+		if require {
+			return errRequireSignature
+		}
+
+		return nil
+	}
+
+	key, err := oci.LoadPublicKey(keyPath)
+	if err != nil {
+		return err
+	}
+
+	opts.SignatureKey = key
+	opts.RequireSignature = require
+
+	return nil
+}
+
+// renderProgress returns an oci.ProgressFunc rendering the blob transfers progress to out,
+// on a single updating line if out is a terminal and once per blob otherwise.
+func renderProgress(out *os.File) oci.ProgressFunc {
+	fi, err := out.Stat()
+	tty := err == nil && fi.Mode()&os.ModeCharDevice != 0
+
+	// Blobs are transferred in parallel, keep their lines whole.
+	var mu sync.Mutex
+
+	return func(p oci.Progress) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		done := p.Done >= p.Total
+
+		switch {
+		case tty && done:
+			fmt.Fprintf(out, "\r\x1b[K%s\n", p)
+		case tty:
+			fmt.Fprintf(out, "\r\x1b[K%s", p)
+		case done:
+			fmt.Fprintln(out, p)
+		}
+	}
+}
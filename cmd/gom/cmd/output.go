@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/damdo/gokrazy-machine/internal/machine"
+)
+
+const outputText, outputJSON = "text", "json"
+
+var errUnsupportedOutput = errors.New("error unsupported output format")
+
+func validateOutput(output string) error {
+	switch output {
+	case outputText, outputJSON:
+		return nil
+	default:
+		return fmt.Errorf("%w: %s, expected one of: %s, %s", errUnsupportedOutput, output, outputText, outputJSON)
+	}
+}
+
+// printMachineInfo prints the summary of the running machine to w, in the output format.
+func printMachineInfo(w io.Writer, info machine.Info, output string) error {
+	if output == outputJSON {
+		if err := json.NewEncoder(w).Encode(info); err != nil {
+			return fmt.Errorf("failed to json encode machine info: %w", err)
+		}
+
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+
+	fmt.Fprintf(tw, "machine %s is running (pid %d)\n", info.Name, info.PID)
+
+	if info.WebURL != "" {
+		fmt.Fprintf(tw, "  web UI:\t%s\n", info.WebURL)
+	}
+
+	if info.SSHPort != 0 {
+		fmt.Fprintf(tw, "  ssh port:\t%d (gom ssh %s)\n", info.SSHPort, info.Name)
+	}
+
+	if info.HTTPSPort != 0 {
+		fmt.Fprintf(tw, "  https port:\t%d\n", info.HTTPSPort)
+	}
+
+	if len(info.Ports) > 0 {
+		ports := make([]string, len(info.Ports))
+		for i, p := range info.Ports {
+			ports[i] = p.String()
+		}
+
+		fmt.Fprintf(tw, "  ports:\t%s\n", strings.Join(ports, ","))
+	}
+
+	fmt.Fprintf(tw, "  serial log:\t%s\n", info.SerialLog)
+
+	return tw.Flush()
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -12,14 +13,17 @@ import (
 	"os/exec"
 	"path"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/damdo/gokrazy-machine/internal/disk"
 	"github.com/damdo/gokrazy-machine/internal/gaf"
+	"github.com/damdo/gokrazy-machine/internal/machine"
 	"github.com/damdo/gokrazy-machine/internal/oci"
 	"github.com/damdo/gokrazy-machine/internal/ports"
 	"github.com/damdo/gokrazy-machine/internal/qemu"
+	"github.com/damdo/gokrazy-machine/internal/qmp"
 	"github.com/spf13/cobra"
 )
 
@@ -36,28 +40,57 @@ var playCmd = &cobra.Command{
 }
 
 type playImplConfig struct {
-	baseCmd      string
-	arch         string
-	full         string
-	netNat       string
-	netShared    string
-	oci          string
-	gaf          string
-	boot         string
-	root         string
-	mbr          string
-	mem          string
-	cores        string
-	ociUser      string
-	ociPassword  string
-	ociPlainHTTP bool
+	baseCmd        string
+	arch           string
+	full           string
+	netNat         string
+	netShared      string
+	oci            string
+	ociLayout      string
+	ociArchive     string
+	gaf            string
+	boot           string
+	root           string
+	mbr            string
+	mem            string
+	cores          string
+	diskSize       string
+	overlay        string
+	perm           string
+	skipSBOMVerify bool
+	ociUser        string
+	ociPassword    string
+	ociPassStdin   bool
+	ociPlainHTTP   bool
+	ociNoCache     bool
+	offline        bool
+	signatureKey   string
+	requireSig     bool
+	detach         bool
+	name           string
+	output         string
+	waitReady      bool
+	readyPath      string
+	readySerial    string
+	readyTimeout   time.Duration
 }
 
 const arm64, amd64 = "arm64", "amd64"
 const modeOCI, modeFull, modeParts, modeGaf = "oci", "full", "parts", "gaf"
+const overlayNone, overlayDiscard, overlayKeep, overlayCommit = "none", "discard", "keep", "commit"
 
 var playImpl playImplConfig
 var errUnsupportedArch = errors.New("error unsupported architecture")
+var errUnsupportedOverlay = errors.New("error unsupported overlay mode")
+var errPermFullMode = errors.New("error --perm is not supported in full disk mode")
+var errJSONOutputForeground = errors.New("error `--output json` requires `--detach`")
+var errUnrecognizedMode = errors.New("unrecognized mode, please specify either: " +
+	" `--oci` or `--oci-layout` or `--oci-archive` or `--gaf` or `--full` or (`--mbr` + `--boot` + `--root`)")
+var errNetSharedNotDarwin = errors.New("error --net-shared is only supported on macOS")
+var errWaitReadyForeground = errors.New("error `--wait-ready` requires `--detach`")
+var errInvalidReadyPath = errors.New("error --ready-path must start with /")
+var errSignatureNotOCI = errors.New("error `--signature-key` and `--require-signature` are only supported" +
+	" with `--oci`, `--oci-layout` or `--oci-archive`")
 
 func init() {
 	playCmd.Flags().StringVar(&playImpl.arch, "arch", amd64, "arch")
@@ -65,66 +98,205 @@ func init() {
 	playCmd.Flags().StringVar(&playImpl.gaf, "gaf", "", "path to the .gaf (gokrazy archive format) of the drive file")
 	playCmd.Flags().StringVar(&playImpl.oci, "oci", "", "path to the remote oci artifact reference "+
 		"(e.g. docker.io/damdo/gokrazy:sample-amd64)")
+	playCmd.Flags().StringVar(&playImpl.ociLayout, "oci-layout", "", "path to an oci image layout directory holding the "+
+		"oci artifact, optionally followed by :<tag> or @<digest> (e.g. /mnt/images:sample)")
+	playCmd.Flags().StringVar(&playImpl.ociArchive, "oci-archive", "", "path to a tar archive of an oci image layout holding the "+
+		"oci artifact, optionally followed by :<tag> or @<digest> (e.g. /mnt/images.tar:sample)")
 	playCmd.Flags().StringVar(&playImpl.boot, "boot", "", "path to the boot part of the drive")
 	playCmd.Flags().StringVar(&playImpl.root, "root", "", "path to the root part of the drive")
 	playCmd.Flags().StringVar(&playImpl.ociUser, "oci.user", "", "the username for the OCI registry")
 	playCmd.Flags().StringVar(&playImpl.ociPassword, "oci.password", "", "the password for the OCI registry")
+	playCmd.Flags().BoolVar(&playImpl.ociPassStdin, "oci.password-stdin", false, "read the password for the OCI registry from stdin")
 	playCmd.Flags().BoolVar(&playImpl.ociPlainHTTP, "oci.plainHTTP", false, "allow the use of plain HTTP for OCI registry")
+	playCmd.Flags().BoolVar(&playImpl.ociNoCache, "oci.no-cache", false, "do not use the local cache of pulled OCI artifacts")
+	playCmd.Flags().BoolVar(&playImpl.offline, "offline", false, "boot the --oci reference from the local cache"+
+		" without contacting the OCI registry")
+	playCmd.Flags().StringVar(&playImpl.signatureKey, "signature-key", "", "path to the PEM encoded ECDSA or ed25519"+
+		" public key (e.g. cosign.pub) to verify the cosign signatures of the oci artifact with")
+	playCmd.Flags().BoolVar(&playImpl.requireSig, "require-signature", false, "refuse to play oci artifacts"+
+		" without a signature verified by --signature-key")
 	playCmd.Flags().StringVar(&playImpl.mbr, "mbr", "", "path to the mbr part of the drive")
 	playCmd.Flags().StringVar(&playImpl.mem, "memory", "1G", "memory, expects a non-negative number below 2^64."+
 		" Optional suffix k, M, G, T, P or E means kilo-, mega-, giga-, tera-, peta- and exabytes, respectively.")
 	playCmd.Flags().StringVar(&playImpl.cores, "cores", "1", "number of cores available to the guest OS.")
+	playCmd.Flags().StringVar(&playImpl.diskSize, "disk-size", "2G", "size of the disk assembled in parts, gaf and oci modes."+
+		" Optional suffix k, M, G, T, P or E means kilo-, mega-, giga-, tera-, peta- and exabytes, respectively.")
+	playCmd.Flags().StringVar(&playImpl.overlay, "overlay", overlayDiscard, "copy-on-write overlay mode for the --full image,"+
+		" one of: discard (drop the changes on exit), keep (keep the changes in an overlay next to the image,"+
+		" reused on the next run), commit (write the changes to the image on exit), none (write straight to the image)")
+	playCmd.Flags().StringVar(&playImpl.perm, "perm", "", "path to a file persisting the perm partition across runs"+
+		" in parts, gaf and oci modes, created and ext4 formatted if missing")
+	playCmd.Flags().BoolVar(&playImpl.skipSBOMVerify, "skip-sbom-verify", false, "skip the verification of the gaf or oci"+
+		" sbom against the root image")
 	playCmd.Flags().StringVar(&playImpl.netNat, "net-nat", "", "net nat")
 	playCmd.Flags().StringVar(&playImpl.netShared, "net-shared", "", "net shared")
+	playCmd.Flags().StringVar(&playImpl.name, "name", "", "name of the machine, unique among the running ones,"+
+		" to refer to it with the other commands (default gokrazy-machine-<random>)")
+	playCmd.Flags().BoolVar(&playImpl.detach, "detach", false, "run the machine in the background and print its name,"+
+		" see gom ps, gom logs and gom stop")
+	playCmd.Flags().StringVarP(&playImpl.output, "output", "o", outputText, "format of the summary of the started machine"+
+		" (web UI URL, ports, serial log, pid), one of: text, json (printed on stdout, requires --detach)")
+	playCmd.Flags().BoolVar(&playImpl.waitReady, "wait-ready", false, "with --detach, wait for the machine to answer --ready-path"+
+		" on the port forwarded to the guest port 80, and to print --ready-serial on its serial console if set,"+
+		" stopping it and exiting non-zero if it isn't ready within --ready-timeout")
+	playCmd.Flags().StringVar(&playImpl.readyPath, "ready-path", machine.DefaultReadyPath, "HTTP path probed by --wait-ready,"+
+		" ready once it answers with a success or an authentication challenge")
+	playCmd.Flags().StringVar(&playImpl.readySerial, "ready-serial", "", "text to wait for on the serial console with --wait-ready")
+	playCmd.Flags().DurationVar(&playImpl.readyTimeout, "ready-timeout", 2*time.Minute, "timeout of --wait-ready")
 }
 
-func (r *playImplConfig) play(ctx context.Context, _ []string, _, _ io.Writer) error {
+func (r *playImplConfig) play(ctx context.Context, _ []string, out, errOut io.Writer) error {
+	if err := validateOutput(playImpl.output); err != nil {
+		return err
+	}
+
+	// In the foreground stdout is the serial console.
+	if playImpl.output == outputJSON && !playImpl.detach {
+		return errJSONOutputForeground
+	}
+
+	if err := validateSignatureFlags(); err != nil {
+		return err
+	}
+
+	// In the foreground play only returns once the machine exits, too late to report its readiness.
+	if playImpl.waitReady && !playImpl.detach {
+		return errWaitReadyForeground
+	}
+
+	if playImpl.waitReady && !strings.HasPrefix(playImpl.readyPath, "/") {
+		return fmt.Errorf("%w: %s", errInvalidReadyPath, playImpl.readyPath)
+	}
+
+	diskSize, err := disk.ParseSize(playImpl.diskSize)
+	if err != nil {
+		return fmt.Errorf("error parsing --disk-size: %w", err)
+	}
+
+	if err := disk.ValidateSize(diskSize); err != nil {
+		return fmt.Errorf("error validating --disk-size: %w", err)
+	}
+
+	switch playImpl.overlay {
+	case overlayNone, overlayDiscard, overlayKeep, overlayCommit:
+	default:
+		return fmt.Errorf("%w: %s", errUnsupportedOverlay, playImpl.overlay)
+	}
+
 	// Setup a random source.
 	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
 
+	// The detached gom process runs the machine named, and created, by the one that spawned it.
+	name, detached := os.LookupEnv(envDetachedName)
+	if !detached {
+		name = playImpl.name
+		if name == "" {
+			name = fmt.Sprintf("gokrazy-machine-%s", fmt.Sprintf("%x", rnd.Uint64())[:7])
+		}
+
+		if playImpl.detach {
+			return spawnDetached(ctx, name, out, errOut)
+		}
+
+		if err := machine.Create(name); err != nil {
+			return err
+		}
+	}
+
+	// Release the machine name however the machine ends, from here on errors are returned
+	// for the deferred cleanups to run.
+	defer func() {
+		if err := machine.Remove(name); err != nil {
+			log.Println(err)
+		}
+	}()
+
+	// Record the machine state, for the commands addressing it by name.
+	state := machine.State{Name: name, PID: os.Getpid(), Detached: detached, Started: time.Now()}
+	if err := machine.WriteState(state); err != nil {
+		return err
+	}
+
 	// Setup a base temporary directory for gom.
 	baseDir, err := os.MkdirTemp("", "gom")
 	if err != nil {
-		log.Fatalln(fmt.Errorf("error creating temporary directory: %w", err))
+		return fmt.Errorf("error creating temporary directory: %w", err)
 	}
 
+	// Cleanup the various temp/generated files used.
+	defer func() {
+		if err := os.RemoveAll(baseDir); err != nil {
+			log.Println(fmt.Errorf("error cleaning up temporary directory: %w", err))
+		}
+	}()
+
 	// These are hardcoded values for filenames
 	// that we expect to find in as oci artifacts at the oci reference url
 	// passed in.
-	mbrSource := "mbr.img"
-	bootSource := "boot.img"
-	rootSource := "root.img"
 	sbomSource := "sbom.json"
 	gafSoruce := "disk.gaf"
 	destPath := "disk.img"
 
-	diskFile, _, err := obtainDiskFile(ctx, baseDir, mbrSource, bootSource, rootSource, sbomSource, gafSoruce, destPath)
+	diskFile, mode, err := obtainDiskFile(ctx, baseDir, sbomSource, gafSoruce, destPath, diskSize)
 	if err != nil {
-		log.Fatalln(fmt.Errorf("error obtaining disk file: %w", err))
+		return fmt.Errorf("error obtaining disk file: %w", err)
+	}
+
+	diskFormat := qemu.FormatRaw
+
+	var overlay *qemu.Overlay
+	if mode == modeFull && playImpl.overlay != overlayNone {
+		o, err := setupOverlay(ctx, baseDir, diskFile)
+		if err != nil {
+			return fmt.Errorf("error setting up disk overlay: %w", err)
+		}
+
+		diskFile, diskFormat = o.Path, o.Format
+		overlay = &o
+	}
+
+	disks := []string{diskFile}
+	if overlay != nil {
+		disks = append(disks, overlay.Base)
+	}
+
+	if playImpl.perm != "" {
+		disks = append(disks, playImpl.perm)
+
+		if mode == modeFull {
+			return errPermFullMode
+		}
+
+		if err := setupPerm(ctx, diskFile, diskSize); err != nil {
+			return fmt.Errorf("error setting up perm partition: %w", err)
+		}
 	}
 
 	qemuArgs := []string{
-		"-name", fmt.Sprintf("gokrazy-machine-%s", fmt.Sprintf("%x", rnd.Uint64())[:7]),
+		"-name", name,
 		"-nographic",
 		"-usb",
 		"-m", playImpl.mem,
 		"-smp", fmt.Sprintf("cores=%s", playImpl.cores),
 		"-boot", "order=d",
-		"-drive", "file=" + diskFile + ",format=raw",
+		"-drive", "file=" + diskFile + ",format=" + diskFormat,
 	}
 
+	// Expose a QMP control socket, for gom ctl.
+	qemuArgs = append(qemuArgs, qmp.ServerArgs(machine.QMPSocket(name))...)
+
 	if err := setArchSpecificArgs(baseDir, &qemuArgs); err != nil {
-		log.Fatalln(fmt.Errorf("error setting architecture specific args: %w", err))
+		return fmt.Errorf("error setting architecture specific args: %w", err)
 	}
 
 	// Check if the qemu binary set in baseCmd is present on the system.
 	if _, err := exec.LookPath(playImpl.baseCmd); err != nil {
-		log.Fatalln(fmt.Errorf("error while looking for qemu executable %s, is qemu installed?: %w", playImpl.baseCmd, err))
+		return fmt.Errorf("error while looking for qemu executable %s, is qemu installed?: %w", playImpl.baseCmd, err)
 	}
 
-	needsSudo, err := setNetworkingArgs(&qemuArgs)
+	forwards, needsSudo, err := setNetworkingArgs(&qemuArgs)
 	if err != nil {
-		log.Fatalln(fmt.Errorf("error setting networking args: %w", err))
+		return fmt.Errorf("error setting networking args: %w", err)
 	}
 
 	if needsSudo {
@@ -134,33 +306,147 @@ func (r *playImplConfig) play(ctx context.Context, _ []string, _, _ io.Writer) e
 		playImpl.baseCmd = "sudo"
 	}
 
-	qemuRun := exec.CommandContext(ctx, playImpl.baseCmd, qemuArgs...)
+	// Stop qemu also if the machine doesn't pass its readiness probe.
+	qemuCtx, stopQemu := context.WithCancel(ctx)
+	defer stopQemu()
+
+	qemuRun := exec.CommandContext(qemuCtx, playImpl.baseCmd, qemuArgs...)
+
+	serialLog, err := os.Create(machine.SerialLog(name))
+	if err != nil {
+		return fmt.Errorf("error creating serial log: %w", err)
+	}
+	defer serialLog.Close()
 
-	// Pipe Stderr and Stdout to the OSes ones.
-	qemuRun.Stdin = os.Stdin
+	// Pipe Stderr and Stdout to the OSes ones, keeping a log of the serial console.
+	// Detached machines have no terminal, their serial console is only logged.
 	qemuRun.Stderr = os.Stderr
-	qemuRun.Stdout = os.Stdout
+	if detached {
+		qemuRun.Stdout = serialLog
+	} else {
+		qemuRun.Stdin = os.Stdin
+		qemuRun.Stdout = io.MultiWriter(os.Stdout, serialLog)
+	}
 
 	log.Println("about to start qemu with config:")
 	fmt.Println(fmtQemuConfig(qemuRun.Args))
 
 	log.Println("starting qemu:")
 	if err := qemuRun.Start(); err != nil {
-		log.Fatalln(fmt.Errorf("%v: %w", qemuRun.Args, err))
+		return fmt.Errorf("%v: %w", qemuRun.Args, err)
+	}
+
+	state.QemuPID = qemuRun.Process.Pid
+	state.Args = qemuRun.Args
+	state.Ports = forwards
+	state.Disks = disks
+	state.SerialLog = serialLog.Name()
+
+	if err := machine.WriteState(state); err != nil {
+		log.Println(err)
+	}
+
+	log.Printf("started machine %s, control it with: gom ctl %s <command>", name, name)
+
+	// The detached machine summary is printed by the gom process that spawned it.
+	if !detached {
+		if err := printMachineInfo(errOut, state.Info(), outputText); err != nil {
+			log.Println(err)
+		}
+	}
+
+	readiness := make(chan error, 1)
+	if playImpl.waitReady {
+		go func() {
+			err := waitReady(ctx, state)
+			readiness <- err
+
+			if err != nil {
+				log.Printf("stopping machine %s, not ready", name)
+				stopQemu()
+			}
+		}()
 	}
 
 	if err := qemuRun.Wait(); err != nil {
 		log.Println(fmt.Errorf("qemu.Wait(): %v", err)) //nolint:goerr113
 	}
 
-	// Cleanup the various temp/generated files used.
-	if err := os.RemoveAll(baseDir); err != nil {
-		log.Println(fmt.Errorf("error cleaning up temporary directory: %w", err))
+	if playImpl.perm != "" {
+		log.Printf("saving perm partition to %s", playImpl.perm)
+		if err := disk.ExtractPerm(diskFile, playImpl.perm); err != nil {
+			log.Println(fmt.Errorf("error saving perm partition: %w", err))
+		}
+	}
+
+	if overlay != nil {
+		switch playImpl.overlay {
+		case overlayCommit:
+			log.Printf("committing overlay %s to %s", overlay.Path, overlay.Base)
+			// The command context is likely cancelled at this point, commit regardless.
+			if err := overlay.Commit(context.Background()); err != nil {
+				log.Println(fmt.Errorf("error committing disk overlay: %w", err))
+			}
+
+		case overlayKeep:
+			log.Printf("kept overlay %s", overlay.Path)
+		}
+	}
+
+	// Exit non-zero if the machine didn't pass its readiness probe, or exited before.
+	if playImpl.waitReady {
+		select {
+		case err := <-readiness:
+			return err
+		default:
+			return fmt.Errorf("%w: %s: %w", machine.ErrNotReady, name, machine.ErrExited)
+		}
+	}
+
+	return nil
+}
+
+// validateSignatureFlags rejects the signature flags for the sources that can't be verified,
+// so that --require-signature never boots an unverified image.
+func validateSignatureFlags() error {
+	if playImpl.signatureKey == "" && !playImpl.requireSig {
+		return nil
+	}
+
+	if playImpl.oci == "" && playImpl.ociLayout == "" && playImpl.ociArchive == "" {
+		return errSignatureNotOCI
+	}
+
+	if playImpl.requireSig && playImpl.signatureKey == "" {
+		return errRequireSignature
 	}
 
 	return nil
 }
 
+// waitReady waits for the started machine to pass its readiness probe, within --ready-timeout,
+// and records it in its state.
+func waitReady(ctx context.Context, state machine.State) error {
+	ctx, cancel := context.WithTimeout(ctx, playImpl.readyTimeout)
+	defer cancel()
+
+	log.Printf("waiting for machine %s to be ready", state.Name)
+
+	opts := machine.ReadyOptions{Path: playImpl.readyPath, SerialMarker: playImpl.readySerial}
+	if err := machine.WaitReady(ctx, state.Name, opts); err != nil {
+		return err
+	}
+
+	state.Ready = true
+	if err := machine.WriteState(state); err != nil {
+		return err
+	}
+
+	log.Printf("machine %s is ready", state.Name)
+
+	return nil
+}
+
 func fmtQemuConfig(cfg []string) string {
 	delimiter := "-----\n"
 
@@ -177,107 +463,90 @@ func fmtQemuConfig(cfg []string) string {
 	return out
 }
 
-func obtainDiskFile(ctx context.Context, baseDir, mbrSourceName,
-	bootSourceName, rootSourceName, _, gafSourceName, destName string) (string, string, error) {
+func obtainDiskFile(ctx context.Context, baseDir, _, gafSourceName, destName string, diskSize int64) (string, string, error) {
 	var diskFile, mode string
 
-	mbrSourcePath := path.Join(baseDir, mbrSourceName)
-	bootSourcePath := path.Join(baseDir, bootSourceName)
-	rootSourcePath := path.Join(baseDir, rootSourceName)
 	// sbomSourcePath := path.Join(baseDir, sbomSourceName)
 	gafSourcePath := path.Join(baseDir, gafSourceName)
 	destPath := path.Join(baseDir, destName)
 
 	switch {
-	case playImpl.gaf != "" || playImpl.oci != "":
+	case playImpl.gaf != "" || playImpl.oci != "" || playImpl.ociLayout != "" || playImpl.ociArchive != "":
 		gafPath := ""
-		if playImpl.oci != "" {
+		if playImpl.oci != "" || playImpl.ociLayout != "" || playImpl.ociArchive != "" {
 			log.Println("starting in oci mode")
 			// Pull OCI artifacts.
-			if err := oci.Pull(ctx, playImpl.oci, playImpl.ociUser, playImpl.ociPassword, baseDir, playImpl.ociPlainHTTP); err != nil {
-				return "", "", fmt.Errorf("error pulling remote oci artifacts: %w", err)
+			opts, err := ociPullOptions(playImpl.ociUser, playImpl.ociPassword, playImpl.ociPassStdin, playImpl.ociPlainHTTP,
+				playImpl.offline, playImpl.ociNoCache)
+			if err != nil {
+				return "", "", err
 			}
 
-			gafPath = gafSourcePath
-			mode = modeOCI
-		} else {
-			log.Println("starting in gaf mode")
+			// Pick the manifest for the machine architecture from multi-arch image indexes.
+			opts.Arch = playImpl.arch
 
-			gafPath = playImpl.gaf
-			mode = modeGaf
-		}
+			if err := ociSignatureOptions(&opts, playImpl.signatureKey, playImpl.requireSig); err != nil {
+				return "", "", err
+			}
 
-		// Extract multi part images from gaf.
+			var layout oci.Layout
+			switch {
+			case playImpl.ociLayout != "":
+				layoutPath, reference := oci.SplitImageLayoutReference(playImpl.ociLayout)
+				layout, err = oci.PullImageLayout(ctx, layoutPath, reference, baseDir, opts)
+			case playImpl.ociArchive != "":
+				archivePath, reference := oci.SplitImageLayoutReference(playImpl.ociArchive)
+				layout, err = oci.PullImageLayout(ctx, archivePath, reference, baseDir, opts)
+			default:
+				layout, err = oci.Pull(ctx, playImpl.oci, baseDir, opts)
+			}
 
-		f, err := os.Open(path.Clean(gafPath))
-		if err != nil {
-			log.Fatalln(fmt.Errorf("unable to open gaf file: %w", err))
-		}
-		fi, err := f.Stat()
-		if err != nil {
-			log.Fatalln(fmt.Errorf("unable to stat gaf file: %w", err))
-		}
+			if err != nil {
+				return "", "", fmt.Errorf("error pulling oci artifacts: %w", err)
+			}
 
-		gafReadClosers, err := gaf.Extract(ctx, f, fi.Size())
-		if err != nil {
-			f.Close()
-			log.Fatalln(fmt.Errorf("unable to extract gaf file content: %w", err))
-		}
+			if layout == oci.LayoutGaf {
+				gafPath = gafSourcePath
+			}
 
-		// MBR.
-		mbrFile, err := os.Create(mbrSourcePath)
-		if err != nil {
-			mbrFile.Close()
-			log.Fatalln(fmt.Errorf("unable to create temporary MBR file: %w", err))
-		}
+			mode = modeOCI
+		} else {
+			log.Println("starting in gaf mode")
 
-		if _, err := io.Copy(mbrFile, gafReadClosers.MBRRC); err != nil {
-			mbrFile.Close()
-			log.Fatalln(fmt.Errorf("unable to write temporary MBR file: %w", err))
+			gafPath = playImpl.gaf
+			mode = modeGaf
 		}
-		mbrFile.Close()
-		gafReadClosers.MBRRC.Close()
 
-		// Boot.
-		bootFile, err := os.Create(bootSourcePath)
-		if err != nil {
-			bootFile.Close()
-			log.Fatalln(fmt.Errorf("unable to create temporary boot file: %w", err))
-		}
+		var sbom []byte
+		var err error
 
-		if _, err := io.Copy(bootFile, gafReadClosers.BootRC); err != nil {
-			bootFile.Close()
-			log.Fatalln(fmt.Errorf("unable to write temporary boot file: %w", err))
+		if gafPath != "" {
+			// Create a full disk img streaming the disk pieces (mbr, boot, root) of the gaf to it.
+			sbom, err = gafToFull(ctx, gafPath, destPath, diskSize)
+		} else {
+			// Create a full disk img from the disk pieces (mbr, boot, root) layers of the oci artifact.
+			sbom, err = partsToFull(baseDir, destPath, diskSize)
 		}
-		bootFile.Close()
-		gafReadClosers.BootRC.Close()
 
-		// Root.
-		rootFile, err := os.Create(rootSourcePath)
 		if err != nil {
-			rootFile.Close()
-			log.Fatalln(fmt.Errorf("unable to create temporary root file: %w", err))
+			return "", "", err
 		}
 
-		if _, err := io.Copy(rootFile, gafReadClosers.RootRC); err != nil {
-			rootFile.Close()
-			log.Fatalln(fmt.Errorf("unable to write temporary root file: %w", err))
-		}
-		rootFile.Close()
-		gafReadClosers.RootRC.Close()
+		switch {
+		case playImpl.skipSBOMVerify:
 
-		log.Printf("merging oci artifact files (disk part images: %s, %s, %s) to a single %s image",
-			mbrSourcePath, bootSourcePath, rootSourcePath, destPath)
+		case sbom == nil:
+			log.Printf("no %s found, skipping sbom verification", gaf.SBOM)
 
-		// Create a full disk img starting from disk pieces (mbr, boot, root).
-		if err := disk.PartsToFull(mbrSourcePath, bootSourcePath, rootSourcePath, destPath); err != nil {
-			log.Fatalln(fmt.Errorf("unable to create full disk img from oci artifact files: %w", err))
+		default:
+			log.Println("verifying sbom against the root image")
+			if err := verifySBOM(sbom, destPath); err != nil {
+				return "", "", fmt.Errorf("error verifying sbom (use --skip-sbom-verify to skip): %w", err)
+			}
 		}
 
 		diskFile = destPath
 
-		f.Close()
-
 	case playImpl.boot != "" && playImpl.root != "" && playImpl.mbr != "":
 		log.Println("starting in multi part disk mode")
 
@@ -285,10 +554,9 @@ func obtainDiskFile(ctx context.Context, baseDir, mbrSourceName,
 			playImpl.mbr, playImpl.boot, playImpl.root, destPath)
 
 		// Create a full disk img starting from disk pieces (mbr, boot, root).
-		if err := disk.PartsToFull(playImpl.mbr, playImpl.boot, playImpl.root, destPath); err != nil {
-			log.Fatalln(
-				fmt.Errorf("unable to create full disk img from files (disk part images: %s, %s, %s): %w",
-					playImpl.mbr, playImpl.boot, playImpl.root, err))
+		if err := disk.PartsToFull(playImpl.mbr, playImpl.boot, playImpl.root, destPath, diskSize); err != nil {
+			return "", "", fmt.Errorf("unable to create full disk img from files (disk part images: %s, %s, %s): %w",
+				playImpl.mbr, playImpl.boot, playImpl.root, err)
 		}
 
 		diskFile = destPath
@@ -301,13 +569,125 @@ func obtainDiskFile(ctx context.Context, baseDir, mbrSourceName,
 		mode = modeFull
 
 	default:
-		log.Fatalln("unrecognized mode, please specify either: " +
-			" `--oci` or `--full` or (`--mbr` + `--boot` + `--root`)")
+		return "", "", errUnrecognizedMode
 	}
 
 	return diskFile, mode, nil
 }
 
+// setupOverlay creates the copy-on-write overlay of the full disk image,
+// in the baseDir unless it has to be kept, in which case an existing one is reused.
+func setupOverlay(ctx context.Context, baseDir, diskFile string) (qemu.Overlay, error) {
+	overlayPath := path.Join(baseDir, "overlay")
+
+	if playImpl.overlay == overlayKeep {
+		overlayPath = diskFile + ".overlay"
+
+		if o, ok := qemu.OpenOverlay(diskFile, overlayPath); ok {
+			log.Printf("reusing %s overlay %s of %s", o.Format, o.Path, diskFile)
+
+			return o, nil
+		}
+	}
+
+	o, err := qemu.CreateOverlay(ctx, diskFile, overlayPath)
+	if err != nil {
+		return qemu.Overlay{}, err
+	}
+
+	log.Printf("created %s overlay %s of %s", o.Format, o.Path, diskFile)
+
+	return o, nil
+}
+
+// setupPerm splices the perm file into the disk file, creating it if missing.
+func setupPerm(ctx context.Context, diskFile string, diskSize int64) error {
+	if _, err := os.Stat(playImpl.perm); errors.Is(err, fs.ErrNotExist) {
+		log.Printf("creating perm file %s", playImpl.perm)
+		if err := disk.CreatePerm(ctx, playImpl.perm, disk.PermSize(diskSize)); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("loading perm partition from %s", playImpl.perm)
+
+	return disk.SplicePerm(diskFile, playImpl.perm)
+}
+
+// gafToFull writes the disk pieces (mbr, boot, root) of the gaf file to a full disk img,
+// returning the content of its sbom.
+func gafToFull(ctx context.Context, gafPath, destPath string, diskSize int64) ([]byte, error) {
+	f, err := os.Open(path.Clean(gafPath))
+	if err != nil {
+		return nil, fmt.Errorf("unable to open gaf file: %w", err)
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("unable to stat gaf file: %w", err)
+	}
+
+	gafReadClosers, err := gaf.Extract(ctx, f, fi.Size())
+	if err != nil {
+		return nil, fmt.Errorf("unable to extract gaf file content: %w", err)
+	}
+	defer gafReadClosers.MBRRC.Close()
+	defer gafReadClosers.BootRC.Close()
+	defer gafReadClosers.RootRC.Close()
+	defer gafReadClosers.SBOMRC.Close()
+
+	log.Printf("writing gaf content (disk part images: %s, %s, %s) to a single %s image",
+		gaf.MBR, gaf.Boot, gaf.Root, destPath)
+
+	if err := disk.ReadersToFull(gafReadClosers.MBRRC, gafReadClosers.BootRC, gafReadClosers.RootRC,
+		destPath, diskSize); err != nil {
+		return nil, fmt.Errorf("unable to create full disk img from gaf content: %w", err)
+	}
+
+	sbom, err := io.ReadAll(gafReadClosers.SBOMRC)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read gaf sbom: %w", err)
+	}
+
+	return sbom, nil
+}
+
+// partsToFull writes the disk pieces (mbr, boot, root) in dir to a full disk img,
+// returning the content of the sbom in dir, or nil if there is none.
+func partsToFull(dir, destPath string, diskSize int64) ([]byte, error) {
+	log.Printf("merging disk part images: %s, %s, %s in %s to a single %s image",
+		gaf.MBR, gaf.Boot, gaf.Root, dir, destPath)
+
+	if err := disk.PartsToFull(path.Join(dir, gaf.MBR), path.Join(dir, gaf.Boot),
+		path.Join(dir, gaf.Root), destPath, diskSize); err != nil {
+		return nil, fmt.Errorf("unable to create full disk img from parts in %s: %w", dir, err)
+	}
+
+	sbom, err := os.ReadFile(path.Join(dir, gaf.SBOM))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("unable to read sbom: %w", err)
+	}
+
+	return sbom, nil
+}
+
+// verifySBOM checks the sbom against the root partition of the full disk img.
+func verifySBOM(sbom []byte, diskFile string) error {
+	s, err := gaf.ParseSBOM(bytes.NewReader(sbom))
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(diskFile)
+	if err != nil {
+		return fmt.Errorf("unable to open disk file: %w", err)
+	}
+	defer f.Close()
+
+	return s.VerifyRoot(io.NewSectionReader(f, disk.RootPartitionOffset, disk.RootPartitionSize))
+}
+
 func setArchSpecificArgs(baseDir string, qemuArgs *[]string) error {
 	var archArgs []string
 	var biosFilePerm fs.FileMode = 0644
@@ -344,15 +724,24 @@ func setArchSpecificArgs(baseDir string, qemuArgs *[]string) error {
 	return nil
 }
 
-func setNetworkingArgs(qemuArgs *[]string) (bool, error) {
+// setNetworkingArgs appends the networking args to qemuArgs,
+// returning the ports forwarded to the machine and whether qemu needs sudo.
+func setNetworkingArgs(qemuArgs *[]string) ([]machine.Port, bool, error) {
 	var needsSudo bool
+	var forwards []machine.Port
 	defaultOpenPortsNumber := 3
 
 	switch {
 	case playImpl.netNat == "" && playImpl.netShared == "":
 		freePorts, err := ports.GetFreePorts(defaultOpenPortsNumber)
 		if err != nil {
-			return false, fmt.Errorf("error getting free ports: %w", err)
+			return nil, false, fmt.Errorf("error getting free ports: %w", err)
+		}
+
+		forwards = []machine.Port{
+			{Host: freePorts[0], Guest: machine.GuestPortHTTP},
+			{Host: freePorts[1], Guest: machine.GuestPortHTTPS},
+			{Host: freePorts[2], Guest: machine.GuestPortSSH},
 		}
 
 		// NAT net with port forwarding.
@@ -372,13 +761,17 @@ func setNetworkingArgs(qemuArgs *[]string) (bool, error) {
 		netNat := []string{"-netdev", "user,id=net0", "-device", "e1000,netdev=net0"}
 		for _, p := range ports {
 			netNat[1] += hostFwdPrefix + p
+
+			if fwd, ok := parseHostFwd(p); ok {
+				forwards = append(forwards, fwd)
+			}
 		}
 
 		*qemuArgs = append(*qemuArgs, netNat...)
 
 	case playImpl.netShared != "":
 		if runtime.GOOS != "darwin" {
-			log.Fatalln("error --net-shared is only supported on macOS")
+			return nil, false, errNetSharedNotDarwin
 		}
 
 		needsSudo = true
@@ -393,5 +786,25 @@ func setNetworkingArgs(qemuArgs *[]string) (bool, error) {
 		*qemuArgs = append(*qemuArgs, netShared...)
 	}
 
-	return needsSudo, nil
+	return forwards, needsSudo, nil
+}
+
+// parseHostFwd parses the [hostaddr:]hostport-[guestaddr]:guestport part of a qemu hostfwd rule.
+func parseHostFwd(rule string) (machine.Port, bool) {
+	host, guest, ok := strings.Cut(rule, "-")
+	if !ok {
+		return machine.Port{}, false
+	}
+
+	hostPort, err := strconv.Atoi(host[strings.LastIndex(host, ":")+1:])
+	if err != nil {
+		return machine.Port{}, false
+	}
+
+	guestPort, err := strconv.Atoi(guest[strings.LastIndex(guest, ":")+1:])
+	if err != nil {
+		return machine.Port{}, false
+	}
+
+	return machine.Port{Host: hostPort, Guest: guestPort}, true
 }
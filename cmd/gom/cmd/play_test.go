@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/damdo/gokrazy-machine/internal/machine"
+)
+
+func TestPlayFailureReleasesName(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing.img")
+
+	tests := []struct {
+		name    string
+		config  playImplConfig
+		wantErr error
+	}{
+		{name: "no disk", wantErr: errUnrecognizedMode},
+		{name: "missing parts", config: playImplConfig{mbr: missing, boot: missing, root: missing}, wantErr: os.ErrNotExist},
+		{name: "perm in full mode", config: playImplConfig{full: missing, perm: missing, overlay: overlayNone}, wantErr: errPermFullMode},
+	}
+
+	saved := playImpl
+	t.Cleanup(func() { playImpl = saved })
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+
+			tmp := t.TempDir()
+			t.Setenv("TMPDIR", tmp)
+
+			const name = "failing"
+
+			playImpl = tt.config
+			playImpl.name = name
+			playImpl.diskSize = "2G"
+			playImpl.output = outputText
+			if playImpl.overlay == "" {
+				playImpl.overlay = overlayDiscard
+			}
+
+			if err := playImpl.play(context.Background(), nil, io.Discard, io.Discard); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("play() = %v, want %v", err, tt.wantErr)
+			}
+
+			if _, err := machine.Lookup(name); !errors.Is(err, machine.ErrNotFound) {
+				t.Errorf("Lookup() after a failed play = %v, want %v", err, machine.ErrNotFound)
+			}
+
+			if entries, err := os.ReadDir(tmp); err != nil || len(entries) != 0 {
+				t.Errorf("temporary directory after a failed play holds %d entries (%v), want none", len(entries), err)
+			}
+
+			if err := machine.Create(name); err != nil {
+				t.Errorf("Create() after a failed play = %v, want nil", err)
+			}
+		})
+	}
+}
+
+func TestPlayWaitReadyForeground(t *testing.T) {
+	saved := playImpl
+	t.Cleanup(func() { playImpl = saved })
+
+	playImpl = playImplConfig{waitReady: true, readyPath: "/", output: outputText}
+
+	if err := playImpl.play(context.Background(), nil, io.Discard, io.Discard); !errors.Is(err, errWaitReadyForeground) {
+		t.Errorf("play() = %v, want %v", err, errWaitReadyForeground)
+	}
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/damdo/gokrazy-machine/internal/machine"
+	"github.com/spf13/cobra"
+)
+
+// portCmd is gom port.
+var portCmd = &cobra.Command{
+	Use:   "port <name> <guest-port>",
+	Short: "prints the host address a guest port of a running gokrazy machine is forwarded to",
+	Long: `prints the host address a guest TCP port of a running gokrazy machine is forwarded to` +
+		` (e.g. gom port my-machine 80)`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return portImpl.port(args[0], args[1], cmd.OutOrStdout())
+	},
+}
+
+type portImplConfig struct{}
+
+var portImpl portImplConfig
+
+var (
+	errInvalidGuestPort = errors.New("error invalid guest port")
+	errPortNotForwarded = errors.New("error guest port is not forwarded")
+)
+
+func (r *portImplConfig) port(name, guestPort string, out io.Writer) error {
+	guest, err := strconv.Atoi(guestPort)
+	if err != nil || guest < 1 || guest > 65535 {
+		return fmt.Errorf("%w: %s", errInvalidGuestPort, guestPort)
+	}
+
+	s, err := machine.Lookup(name)
+	if err != nil {
+		return err
+	}
+
+	p, ok := s.Port(guest)
+	if !ok {
+		return fmt.Errorf("%w: %d/tcp of machine %s", errPortNotForwarded, guest, name)
+	}
+
+	fmt.Fprintf(out, "localhost:%d\n", p.Host)
+
+	return nil
+}
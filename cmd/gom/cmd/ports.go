@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/damdo/gokrazy-machine/internal/machine"
+	"github.com/spf13/cobra"
+)
+
+// portsCmd is gom ports.
+var portsCmd = &cobra.Command{
+	Use:   "ports <name>",
+	Short: "lists the ports forwarded to a running gokrazy machine",
+	Long:  `lists the ports of the host forwarded to the guest ports of a running gokrazy machine`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return portsImpl.ports(args[0], cmd.OutOrStdout())
+	},
+}
+
+type portsImplConfig struct{}
+
+var portsImpl portsImplConfig
+
+func (r *portsImplConfig) ports(name string, out io.Writer) error {
+	s, err := machine.Lookup(name)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range s.Ports {
+		fmt.Fprintf(out, "%d/tcp -> localhost:%d\n", p.Guest, p.Host)
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/damdo/gokrazy-machine/internal/machine"
+	"github.com/damdo/gokrazy-machine/internal/qmp"
+	"github.com/spf13/cobra"
+)
+
+// psStatusTimeout is the timeout of the query of the run status of each machine.
+const psStatusTimeout = time.Second
+
+const statusStarting, statusUnknown = "starting", "unknown"
+
+// psCmd is gom ps.
+var psCmd = &cobra.Command{
+	Use:   "ps",
+	Short: "lists the running gokrazy machines",
+	Long:  `lists the running gokrazy machines, foreground and detached ones`,
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		return psImpl.ps(cmd.Context(), cmd.OutOrStdout())
+	},
+}
+
+type psImplConfig struct{}
+
+var psImpl psImplConfig
+
+func (r *psImplConfig) ps(ctx context.Context, out io.Writer) error {
+	states, err := machine.List()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "NAME\tSTATUS\tPID\tPORTS\tSTARTED")
+
+	for _, s := range states {
+		ports := make([]string, len(s.Ports))
+		for i, p := range s.Ports {
+			ports[i] = p.String()
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", s.Name, machineStatus(ctx, s), pid(s.QemuPID),
+			strings.Join(ports, ","), s.Started.Format(time.RFC3339))
+	}
+
+	return w.Flush()
+}
+
+// machineStatus returns the run status of the machine, queried through its QMP socket.
+func machineStatus(ctx context.Context, s machine.State) string {
+	if s.Starting() {
+		return statusStarting
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, psStatusTimeout)
+	defer cancel()
+
+	c, err := qmp.Dial(ctx, machine.QMPSocket(s.Name))
+	if err != nil {
+		return statusUnknown
+	}
+	defer c.Close()
+
+	status, err := c.QueryStatus(ctx)
+	if err != nil {
+		return statusUnknown
+	}
+
+	return status.Status
+}
+
+func pid(p int) string {
+	if p == 0 {
+		return "-"
+	}
+
+	return strconv.Itoa(p)
+}
@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/damdo/gokrazy-machine/internal/gaf"
+	"github.com/damdo/gokrazy-machine/internal/oci"
+	"github.com/spf13/cobra"
+)
+
+// pushCmd is gom push.
+var pushCmd = &cobra.Command{
+	Use:   "push",
+	Short: "pushes a gokrazy image as an OCI artifact",
+	Long: `pushes a gokrazy image, from disk parts, a full disk image or a .gaf, ` +
+		`as an OCI artifact that can be run with gom play --oci`,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		return pushImpl.push(cmd.Context(), cmd.OutOrStdout())
+	},
+}
+
+type pushImplConfig struct {
+	oci          string
+	arch         string
+	full         string
+	gaf          string
+	mbr          string
+	boot         string
+	root         string
+	sbom         string
+	ociUser      string
+	ociPassword  string
+	ociPassStdin bool
+	ociPlainHTTP bool
+	layout       string
+}
+
+var pushImpl pushImplConfig
+
+var gafMemberFilePermission os.FileMode = 0644
+
+var (
+	errMissingPushReference = errors.New("missing oci reference, please specify `--oci`")
+	errMissingPushSources   = errors.New("missing sources, please specify either: " +
+		"`--gaf` or `--full` or (`--mbr` + `--boot` + `--root` + `--sbom`)")
+	errUnsupportedPushLayout = errors.New("error unsupported oci artifact layout")
+)
+
+func init() {
+	pushCmd.Flags().StringVar(&pushImpl.oci, "oci", "", "the oci artifact reference to push to "+
+		"(e.g. docker.io/damdo/gokrazy:sample-amd64)")
+	pushCmd.Flags().StringVar(&pushImpl.arch, "arch", "", "the architecture of the image, if set the reference "+
+		"is tagged with a multi-arch image index adding the image to the ones already pushed for other architectures")
+	pushCmd.Flags().StringVar(&pushImpl.full, "full", "", "path to the img of the full drive file")
+	pushCmd.Flags().StringVar(&pushImpl.gaf, "gaf", "", "path to the .gaf (gokrazy archive format) of the drive file")
+	pushCmd.Flags().StringVar(&pushImpl.mbr, "mbr", "", "path to the mbr part of the drive")
+	pushCmd.Flags().StringVar(&pushImpl.boot, "boot", "", "path to the boot part of the drive")
+	pushCmd.Flags().StringVar(&pushImpl.root, "root", "", "path to the root part of the drive")
+	pushCmd.Flags().StringVar(&pushImpl.sbom, "sbom", "", "path to the sbom.json of the drive")
+	pushCmd.Flags().StringVar(&pushImpl.ociUser, "oci.user", "", "the username for the OCI registry")
+	pushCmd.Flags().StringVar(&pushImpl.ociPassword, "oci.password", "", "the password for the OCI registry")
+	pushCmd.Flags().BoolVar(&pushImpl.ociPassStdin, "oci.password-stdin", false, "read the password for the OCI registry from stdin")
+	pushCmd.Flags().BoolVar(&pushImpl.ociPlainHTTP, "oci.plainHTTP", false, "allow the use of plain HTTP for OCI registry")
+	pushCmd.Flags().StringVar(&pushImpl.layout, "layout", string(oci.LayoutGaf), "layout of the layers of the oci artifact"+
+		" (see docs/oci.md), one of: gaf (a disk.gaf layer), parts (mbr.img, boot.img and root.img layers),"+
+		" both along with a sbom.json layer")
+}
+
+func (r *pushImplConfig) push(ctx context.Context, out io.Writer) error {
+	if r.oci == "" {
+		return errMissingPushReference
+	}
+
+	if r.layout != string(oci.LayoutGaf) && r.layout != string(oci.LayoutParts) {
+		return fmt.Errorf("%w: %s, expected one of: %s, %s", errUnsupportedPushLayout, r.layout, oci.LayoutGaf, oci.LayoutParts)
+	}
+
+	password, err := ociPassword(r.ociPassword, r.ociPassStdin)
+	if err != nil {
+		return err
+	}
+
+	tmpDir, err := os.MkdirTemp("", "gom")
+	if err != nil {
+		return fmt.Errorf("error creating temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	gafPath := r.gaf
+
+	switch {
+	case gafPath != "":
+
+	case r.full != "" || r.mbr != "" || r.boot != "" || r.root != "" || r.sbom != "":
+		gafPath = filepath.Join(tmpDir, oci.LayerGaf)
+
+		pack := gafPackImplConfig{full: r.full, mbr: r.mbr, boot: r.boot, root: r.root, sbom: r.sbom, output: gafPath}
+		if err := pack.pack(ctx); err != nil {
+			return err
+		}
+
+	default:
+		return errMissingPushSources
+	}
+
+	// The layers are extracted from the gaf, verified against its checksums manifest.
+	layers := []oci.Layer{{Title: oci.LayerGaf, Path: gafPath}}
+	members := []string{gaf.SBOM}
+
+	if r.layout == string(oci.LayoutParts) {
+		layers = nil
+		members = []string{gaf.MBR, gaf.Boot, gaf.Root, gaf.SBOM}
+	}
+
+	if err := extractGafMembers(ctx, gafPath, tmpDir, members...); err != nil {
+		return err
+	}
+
+	for _, member := range members {
+		layers = append(layers, oci.Layer{Title: member, Path: filepath.Join(tmpDir, member)})
+	}
+
+	desc, err := oci.Push(ctx, r.oci, layers, oci.PushOptions{
+		Username:  r.ociUser,
+		Password:  password,
+		PlainHTTP: r.ociPlainHTTP,
+		Progress:  renderProgress(os.Stderr),
+		Arch:      r.arch,
+	})
+	if err != nil {
+		return fmt.Errorf("error pushing oci artifact: %w", err)
+	}
+
+	log.Printf("pushed %s", r.oci)
+	fmt.Fprintln(out, desc.Digest)
+
+	return nil
+}
+
+// extractGafMembers writes the members of the gaf at gafPath with the names to dir, each to a file named after it.
+// Only the members with the names are opened.
+func extractGafMembers(ctx context.Context, gafPath, dir string, names ...string) error {
+	f, err := os.Open(gafPath)
+	if err != nil {
+		return fmt.Errorf("unable to open gaf file %s: %w", gafPath, err)
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("unable to stat gaf file %s: %w", gafPath, err)
+	}
+
+	for _, name := range names {
+		rc, err := gaf.ExtractMember(ctx, f, fi.Size(), name)
+		if err != nil {
+			return fmt.Errorf("unable to extract gaf file %s: %w", gafPath, err)
+		}
+
+		err = writeGafMember(rc, filepath.Join(dir, name))
+		rc.Close()
+
+		if err != nil {
+			return fmt.Errorf("unable to extract %s from gaf file %s: %w", name, gafPath, err)
+		}
+	}
+
+	return nil
+}
+
+func writeGafMember(r io.Reader, dest string) error {
+	f, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, gafMemberFilePermission)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, r); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
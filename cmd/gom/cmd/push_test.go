@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/damdo/gokrazy-machine/internal/gaf"
+)
+
+func TestExtractGafMembers(t *testing.T) {
+	dir := t.TempDir()
+
+	gafPath := filepath.Join(dir, "disk.gaf")
+	f, err := os.Create(gafPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := gaf.Create(context.Background(), f, gaf.Readers{
+		MBR:  strings.NewReader("mbr"),
+		Boot: strings.NewReader("boot"),
+		Root: strings.NewReader("root"),
+		SBOM: strings.NewReader("{}"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(dir, "out")
+	if err := os.Mkdir(out, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := extractGafMembers(context.Background(), gafPath, out, gaf.Boot, gaf.SBOM); err != nil {
+		t.Fatalf("extractGafMembers() = %v, want nil", err)
+	}
+
+	entries, err := os.ReadDir(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]string)
+	for _, e := range entries {
+		b, err := os.ReadFile(filepath.Join(out, e.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got[e.Name()] = string(b)
+	}
+
+	if len(got) != 2 || got[gaf.Boot] != "boot" || got[gaf.SBOM] != "{}" {
+		t.Errorf("extractGafMembers() wrote %q, want only %s and %s", got, gaf.Boot, gaf.SBOM)
+	}
+}
@@ -31,6 +31,19 @@ func Execute() {
 }
 
 func init() {
+	RootCmd.AddCommand(cacheCmd)
+	RootCmd.AddCommand(ctlCmd)
+	RootCmd.AddCommand(diskCmd)
+	RootCmd.AddCommand(exportCmd)
+	RootCmd.AddCommand(gafCmd)
+	RootCmd.AddCommand(inspectCmd)
+	RootCmd.AddCommand(logsCmd)
 	RootCmd.AddCommand(playCmd)
+	RootCmd.AddCommand(portCmd)
+	RootCmd.AddCommand(portsCmd)
+	RootCmd.AddCommand(psCmd)
+	RootCmd.AddCommand(pushCmd)
+	RootCmd.AddCommand(sshCmd)
+	RootCmd.AddCommand(stopCmd)
 	RootCmd.AddCommand(versionCmd)
 }
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+
+	"github.com/damdo/gokrazy-machine/internal/machine"
+	"github.com/spf13/cobra"
+)
+
+// sshCmd is gom ssh.
+var sshCmd = &cobra.Command{
+	Use:   "ssh <name> [-- <ssh args>...]",
+	Short: "connects to a running gokrazy machine with ssh",
+	Long: `connects with ssh to the port forwarded to the ssh port (22) of a running gokrazy machine, ` +
+		`e.g. served by breakglass. The arguments after -- are passed to ssh, e.g. a command to run`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		return sshImpl.ssh(args[0], args[1:])
+	},
+}
+
+type sshImplConfig struct {
+	user string
+}
+
+var sshImpl sshImplConfig
+
+var errNoSSHPort = errors.New("error no port forwarded to the ssh port of the machine")
+
+func init() {
+	sshCmd.Flags().StringVar(&sshImpl.user, "user", "", "the user to log in as")
+}
+
+// ssh replaces gom with ssh connected to the machine with the name.
+func (r *sshImplConfig) ssh(name string, args []string) error {
+	s, err := machine.Lookup(name)
+	if err != nil {
+		return err
+	}
+
+	p, ok := s.Port(machine.GuestPortSSH)
+	if !ok {
+		return fmt.Errorf("%w: %s", errNoSSHPort, name)
+	}
+
+	sshPath, err := exec.LookPath("ssh")
+	if err != nil {
+		return fmt.Errorf("error while looking for ssh executable: %w", err)
+	}
+
+	host := "localhost"
+	if r.user != "" {
+		host = r.user + "@" + host
+	}
+
+	// Every machine gets new host keys and ports, don't record them.
+	sshArgs := []string{"ssh",
+		"-p", strconv.Itoa(p.Host),
+		"-o", "StrictHostKeyChecking=no",
+		"-o", "UserKnownHostsFile=/dev/null",
+		host,
+	}
+
+	return syscall.Exec(sshPath, append(sshArgs, args...), os.Environ())
+}
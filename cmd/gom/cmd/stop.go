@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/damdo/gokrazy-machine/internal/machine"
+	"github.com/spf13/cobra"
+)
+
+// stopPollInterval is the interval the machine is polled at while waiting for it to stop.
+const stopPollInterval = 200 * time.Millisecond
+
+// stopCmd is gom stop.
+var stopCmd = &cobra.Command{
+	Use:   "stop <name>...",
+	Short: "stops running gokrazy machines",
+	Long: `stops running gokrazy machines, asking the guest to power down and ` +
+		`terminating qemu if it is still running after the timeout`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		for _, name := range args {
+			if err := stopImpl.stop(cmd.Context(), name); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	},
+}
+
+type stopImplConfig struct {
+	timeout time.Duration
+	force   bool
+}
+
+var stopImpl stopImplConfig
+
+var errStopTimeout = errors.New("error timed out waiting for the machine to stop")
+
+func init() {
+	stopCmd.Flags().DurationVar(&stopImpl.timeout, "timeout", 30*time.Second, "how long to wait for the guest"+
+		" to power down before terminating qemu")
+	stopCmd.Flags().BoolVar(&stopImpl.force, "force", false, "terminate qemu right away, without asking the guest to power down")
+}
+
+func (r *stopImplConfig) stop(ctx context.Context, name string) error {
+	c, err := dialMachine(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if !r.force {
+		log.Printf("powering down machine %s", name)
+
+		if err := c.SystemPowerdown(ctx); err != nil {
+			return fmt.Errorf("error powering down machine %s: %w", name, err)
+		}
+
+		if waitStopped(ctx, name, r.timeout) {
+			return nil
+		}
+
+		log.Printf("machine %s still running after %s, terminating it", name, r.timeout)
+	}
+
+	if err := c.Quit(ctx); err != nil {
+		return fmt.Errorf("error terminating machine %s: %w", name, err)
+	}
+
+	// Wait for gom to save the perm partition and commit overlays once qemu exits.
+	if !waitStopped(ctx, name, r.timeout) {
+		return fmt.Errorf("%w: %s", errStopTimeout, name)
+	}
+
+	return nil
+}
+
+// waitStopped waits until the machine with the name is stopped and its state cleaned up,
+// reporting whether it did before the timeout.
+func waitStopped(ctx context.Context, name string, timeout time.Duration) bool {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		if _, err := machine.Lookup(name); errors.Is(err, machine.ErrNotFound) {
+			return true
+		}
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(stopPollInterval):
+		}
+	}
+}
@@ -7,6 +7,7 @@ toolchain go1.22.2
 require (
 	github.com/CalebQ42/squashfs v0.8.4
 	github.com/gokrazy/tools v0.0.0-20221120152115-b0f51bdf9220
+	github.com/opencontainers/go-digest v1.0.0
 	github.com/opencontainers/image-spec v1.1.0-rc2
 	github.com/spf13/cobra v1.5.0
 	oras.land/oras-go/v2 v2.0.0-rc.5
@@ -16,7 +17,6 @@ require (
 	github.com/CalebQ42/fuse v0.1.0 // indirect
 	github.com/inconshreveable/mousetrap v1.0.0 // indirect
 	github.com/klauspost/compress v1.16.7 // indirect
-	github.com/opencontainers/go-digest v1.0.0 // indirect
 	github.com/pierrec/lz4/v4 v4.1.18 // indirect
 	github.com/rasky/go-lzo v0.0.0-20200203143853-96a758eda86e // indirect
 	github.com/seaweedfs/fuse v1.2.2 // indirect
@@ -1,12 +1,12 @@
 package disk
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
 
 	"github.com/CalebQ42/squashfs"
-	"github.com/gokrazy/tools/packer"
 )
 
 const mb = 1024 * 1024
@@ -23,97 +23,138 @@ const (
 )
 
 // PartsToFull merges multi parts (mbr, boot, root) image files of a disk into a single disk image file.
-func PartsToFull(mbrSourcePath, bootSourcePath, rootSourcePath, destPath string) error {
-	// TODO: make this a mandatory flag for parts and gaf.
-	var targetStorageBytes = 2147483648
+// The partition layout is detected from the mbr and boot images, to reproduce the one gok produced.
+// The destination disk image is sized to targetStorageBytes.
+func PartsToFull(mbrSourcePath, bootSourcePath, rootSourcePath, destPath string, targetStorageBytes int64) error {
+	mbrFile, err := os.Open(mbrSourcePath)
+	if err != nil {
+		return fmt.Errorf("error opening mbr partition file %s: %w", mbrSourcePath, err)
+	}
+	defer mbrFile.Close()
+
+	bootFile, err := os.Open(bootSourcePath)
+	if err != nil {
+		return fmt.Errorf("error opening boot partition file %s: %w", bootSourcePath, err)
+	}
+	defer bootFile.Close()
 
-	hostname, err := getHostname(rootSourcePath)
+	rootFile, err := os.Open(rootSourcePath)
 	if err != nil {
-		return fmt.Errorf("error getting hostname from root file: %w", err)
+		return fmt.Errorf("error opening root partition file %s: %w", rootSourcePath, err)
+	}
+	defer rootFile.Close()
+
+	return ReadersToFull(mbrFile, bootFile, rootFile, destPath, targetStorageBytes)
+}
+
+// ReadersToFull writes the multi parts (mbr, boot, root) of a disk read from the Readers
+// straight to their offsets in a single disk image file, reading each of them once.
+// The partition layout is detected from the mbr and boot content, to reproduce the one gok produced.
+// The destination disk image is sized to targetStorageBytes.
+func ReadersToFull(mbrSource, bootSource, rootSource io.Reader, destPath string, targetStorageBytes int64) error {
+	if err := ValidateSize(targetStorageBytes); err != nil {
+		return err
 	}
-	p := packer.NewPackForHost(hostname)
 
-	// TODO: read these from the source imgs if possible
-	p.UsePartuuid = true
-	p.UseGPTPartuuid = true
-	p.UseGPT = true
+	mbr, err := io.ReadAll(io.LimitReader(mbrSource, sectorSize+1))
+	if err != nil {
+		return fmt.Errorf("error reading mbr partition: %w", err)
+	}
 
 	f, err := os.Create(destPath)
 	if err != nil {
 		return fmt.Errorf("error creating destination disk file %s: %w", destPath, err)
 	}
+	defer f.Close()
 
-	if err := f.Truncate(int64(targetStorageBytes)); err != nil {
+	if err := f.Truncate(targetStorageBytes); err != nil {
 		return fmt.Errorf("error preparing disk file: %w", err)
 	}
 
-	if err := p.Partition(f, uint64(targetStorageBytes)); err != nil {
-		return fmt.Errorf("error partitioning disk file: %w", err)
+	bootSize, err := writePart(f, bootSource, "boot", BootPartitionOffset, BootPartitionSize)
+	if err != nil {
+		return err
 	}
 
-	if _, err := f.Seek(BootPartitionOffset, io.SeekStart); err != nil {
-		return fmt.Errorf("error seeking disk boot partition start: %w", err)
+	rootSize, err := writePart(f, rootSource, "root", RootPartitionOffset, RootPartitionSize)
+	if err != nil {
+		return err
 	}
 
-	bootFile, err := os.Open(bootSourcePath)
+	hostname, err := getHostname(io.NewSectionReader(f, RootPartitionOffset, rootSize))
 	if err != nil {
-		return fmt.Errorf("error opening boot partition file %s: %w", bootSourcePath, err)
+		return fmt.Errorf("error getting hostname from root partition: %w", err)
 	}
 
-	if _, err := io.Copy(f, bootFile); err != nil {
-		return fmt.Errorf("error writing boot partition to disk file: %w", err)
+	layout, err := DetectLayout(mbr, io.NewSectionReader(f, BootPartitionOffset, bootSize), hostname)
+	if err != nil {
+		return fmt.Errorf("error detecting disk partition layout: %w", err)
 	}
 
+	p := layout.Pack()
+
 	if _, err := f.Seek(MBRPartitionOffset, io.SeekStart); err != nil {
 		return fmt.Errorf("error seeking mbr partition start: %w", err)
 	}
 
-	mbrFile, err := os.Open(mbrSourcePath)
-	if err != nil {
-		return fmt.Errorf("error opening mbr partition file %s: %w", mbrSourcePath, err)
+	if err := p.Partition(f, uint64(targetStorageBytes)); err != nil {
+		return fmt.Errorf("error partitioning disk file: %w", err)
 	}
 
-	if _, err := io.Copy(f, mbrFile); err != nil {
-		return fmt.Errorf("error writing mbr partition to disk file: %w", err)
+	// Only the boot code and the disk signature are taken from the mbr,
+	// the partition table written above is the one matching the disk size.
+	if len(mbr) > mbrPartitionTableOffset {
+		mbr = mbr[:mbrPartitionTableOffset]
 	}
 
-	if _, err := f.Seek(RootPartitionOffset, io.SeekStart); err != nil {
-		return fmt.Errorf("error seeking disk file: %w", err)
+	if _, err := f.WriteAt(mbr, MBRPartitionOffset); err != nil {
+		return fmt.Errorf("error writing mbr partition to disk file: %w", err)
 	}
 
-	tmp, err := os.CreateTemp("", "gokr-packer")
-	if err != nil {
-		return fmt.Errorf("error creating temporary file: %w", err)
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("error closing destination disk file %s: %w", destPath, err)
 	}
-	defer os.Remove(tmp.Name())
-	defer tmp.Close()
 
-	rootFile, err := os.Open(rootSourcePath)
-	if err != nil {
-		return fmt.Errorf("error opening root partition file %s: %w", rootSourcePath, err)
-	}
+	return nil
+}
 
-	if _, err := io.Copy(f, rootFile); err != nil {
-		return fmt.Errorf("error writing root partition to disk file: %w", err)
+// writePart writes the part read from source at offset of the disk file,
+// failing if it is larger than size, before writing past the partition.
+// It returns the size of the part.
+func writePart(f *os.File, source io.Reader, name string, offset, size int64) (int64, error) {
+	n, err := io.Copy(&partWriter{w: io.NewOffsetWriter(f, offset), name: name, size: size}, source)
+	switch {
+	case errors.Is(err, ErrPartTooLarge):
+		return 0, err
+	case err != nil:
+		return 0, fmt.Errorf("error writing %s partition to disk file: %w", name, err)
 	}
 
-	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
-		return fmt.Errorf("error seeking temporary file: %w", err)
-	}
+	return n, nil
+}
 
-	if _, err := io.Copy(f, tmp); err != nil {
-		return fmt.Errorf("error writing temporary file to disk file: %w", err)
+// partWriter writes a part to its partition of size bytes,
+// failing with ErrPartTooLarge instead of writing past its end.
+type partWriter struct {
+	w       io.Writer
+	name    string
+	size    int64
+	written int64
+}
+
+func (p *partWriter) Write(b []byte) (int, error) {
+	if p.written+int64(len(b)) > p.size {
+		return 0, fmt.Errorf("%w: %s is larger than its %d bytes partition", ErrPartTooLarge, p.name, p.size)
 	}
 
-	return nil
+	n, err := p.w.Write(b)
+	p.written += int64(n)
+
+	return n, err
 }
 
-func getHostname(rootSourcePath string) (string, error) {
-	f, err := os.Open(rootSourcePath)
-	if err != nil {
-		return "", fmt.Errorf("error opening root file: %w", err)
-	}
-	rd, err := squashfs.NewReader(f)
+func getHostname(root io.ReaderAt) (string, error) {
+	rd, err := squashfs.NewReader(root)
 	if err != nil {
 		return "", fmt.Errorf("error reading root file squashFS: %w", err)
 	}
@@ -0,0 +1,78 @@
+package disk
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWritePart(t *testing.T) {
+	const (
+		offset = 512
+		size   = 64 * 1024
+	)
+
+	tests := []struct {
+		name    string
+		source  func() io.Reader
+		want    int64
+		wantErr error
+	}{
+		{name: "empty", source: func() io.Reader { return bytes.NewReader(nil) }},
+		{name: "fits", source: func() io.Reader { return bytes.NewReader(bytes.Repeat([]byte{1}, size-1)) }, want: size - 1},
+		{name: "exact fit", source: func() io.Reader { return bytes.NewReader(bytes.Repeat([]byte{1}, size)) }, want: size},
+		{
+			name:    "one byte too large",
+			source:  func() io.Reader { return bytes.NewReader(bytes.Repeat([]byte{1}, size+1)) },
+			wantErr: ErrPartTooLarge,
+		},
+		{
+			// Read in small chunks, the last fitting one is written before the overflow is detected.
+			name:    "too large stream",
+			source:  func() io.Reader { return io.LimitReader(&onesReader{}, 3*size) },
+			wantErr: ErrPartTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := os.Create(filepath.Join(t.TempDir(), "disk.img"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			n, err := writePart(f, tt.source(), "boot", offset, size)
+			if !errors.Is(err, tt.wantErr) || n != tt.want {
+				t.Fatalf("writePart() = %d, %v, want %d, %v", n, err, tt.want, tt.wantErr)
+			}
+
+			fi, err := f.Stat()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if fi.Size() > offset+size {
+				t.Errorf("writePart() wrote up to %d, past the end of the partition at %d", fi.Size(), offset+size)
+			}
+		})
+	}
+}
+
+// onesReader reads 0x01 bytes, 1000 at most per Read.
+type onesReader struct{}
+
+func (onesReader) Read(p []byte) (int, error) {
+	if len(p) > 1000 {
+		p = p[:1000]
+	}
+
+	for i := range p {
+		p[i] = 1
+	}
+
+	return len(p), nil
+}
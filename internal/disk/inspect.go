@@ -0,0 +1,123 @@
+package disk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"regexp"
+	"sort"
+
+	"github.com/CalebQ42/squashfs"
+)
+
+const userDir = "user"
+
+// kernelVersionRegexp matches the version string embedded in Linux kernel images,
+// e.g. "6.1.8 (user@host) #1 SMP".
+var kernelVersionRegexp = regexp.MustCompile(`(\d+\.\d+(?:\.\d+)?[^ \x00]*) \([^()\x00]{1,128}@[^()\x00]{1,128}\) #\d+`)
+
+// Info describes the content of a gokrazy disk.
+type Info struct {
+	// PartitionTable is the partition table of the disk.
+	PartitionTable PartitionTable `json:"partition_table"`
+
+	// ActiveRoot is the number of the Root partition the disk boots from.
+	ActiveRoot int `json:"active_root"`
+
+	// Hostname is the hostname of the gokrazy instance.
+	Hostname string `json:"hostname"`
+
+	// KernelVersion is the version of the Linux kernel, empty if not found.
+	KernelVersion string `json:"kernel_version,omitempty"`
+
+	// Binaries holds the names of the binaries in /user.
+	Binaries []string `json:"binaries"`
+
+	// SBOM is the content of the sbom.json, if any.
+	SBOM json.RawMessage `json:"sbom,omitempty"`
+}
+
+// Inspect reads the Info of the full disk image file at diskPath.
+func Inspect(diskPath string) (Info, error) {
+	f, err := os.Open(diskPath)
+	if err != nil {
+		return Info{}, fmt.Errorf("error opening disk file %s: %w", diskPath, err)
+	}
+	defer f.Close()
+
+	table, err := ReadPartitionTable(f)
+	if err != nil {
+		return Info{}, fmt.Errorf("error reading disk partition table: %w", err)
+	}
+
+	info := Info{
+		PartitionTable: table,
+		ActiveRoot:     rootPartitionNumber,
+	}
+
+	bootPart, ok := table.Partition(bootPartitionNumber)
+	if !ok {
+		return Info{}, fmt.Errorf("%w: boot partition %d", ErrMissingPartition, bootPartitionNumber)
+	}
+
+	root, err := findCmdlineRoot(io.NewSectionReader(f, bootPart.Offset, bootPart.Size))
+	if err != nil {
+		return Info{}, fmt.Errorf("error reading boot partition cmdline: %w", err)
+	}
+
+	if root != nil {
+		info.ActiveRoot = root.partition
+	}
+
+	chunk, m, _, err := findRegexp(io.NewSectionReader(f, bootPart.Offset, bootPart.Size), kernelVersionRegexp)
+	if err != nil {
+		return Info{}, fmt.Errorf("error reading boot partition kernel version: %w", err)
+	}
+
+	if m != nil {
+		info.KernelVersion = string(chunk[m[2]:m[3]])
+	}
+
+	rootPart, ok := table.Partition(info.ActiveRoot)
+	if !ok {
+		return Info{}, fmt.Errorf("%w: active root partition %d", ErrMissingPartition, info.ActiveRoot)
+	}
+
+	rd, err := squashfs.NewReader(io.NewSectionReader(f, rootPart.Offset, rootPart.Size))
+	if err != nil {
+		return Info{}, fmt.Errorf("error reading root partition squashFS: %w", err)
+	}
+
+	hostnamePath := "etc/hostname"
+	hostname, err := rd.ReadFile(hostnamePath)
+	if err != nil {
+		return Info{}, fmt.Errorf("error opening root squashFS hostname file %q: %w", hostnamePath, err)
+	}
+	info.Hostname = string(hostname)
+
+	entries, err := rd.ReadDir(userDir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return Info{}, fmt.Errorf("error listing root squashFS directory %q: %w", userDir, err)
+	}
+
+	for _, e := range entries {
+		if !e.IsDir() {
+			info.Binaries = append(info.Binaries, e.Name())
+		}
+	}
+	sort.Strings(info.Binaries)
+
+	sbom, err := rd.ReadFile(SBOMPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return Info{}, fmt.Errorf("error reading root squashFS SBOM file %q: %w", SBOMPath, err)
+	}
+
+	if len(sbom) > 0 && json.Valid(sbom) {
+		info.SBOM = sbom
+	}
+
+	return info, nil
+}
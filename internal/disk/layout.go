@@ -0,0 +1,327 @@
+package disk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"regexp"
+	"strconv"
+
+	"github.com/gokrazy/tools/packer"
+)
+
+const (
+	sectorSize = 512
+
+	// mbrBootCodeSize is the size of the MBR boot code area,
+	// followed by the 4 bytes disk signature and 2 reserved bytes.
+	mbrBootCodeSize = 440
+
+	// mbrPartitionTableOffset is the offset of the MBR partition table.
+	mbrPartitionTableOffset = 446
+
+	mbrPartitionEntrySize = 16
+
+	// BootPartitionSize is the size of the Boot partition.
+	BootPartitionSize = 100 * mb
+
+	// RootPartitionSize is the size of each of the two Root partitions.
+	RootPartitionSize = 500 * mb
+
+	// PermPartitionOffset is the offset where to find the Perm partition.
+	PermPartitionOffset = RootPartitionOffset + 2*RootPartitionSize
+
+	mbrTypeGPTProtective = 0xEE
+	gptGUIDPrefix        = "60c24cc1-f3f9-427a-8199-"
+	cmdlineScanChunkSize = 1 * mb
+	cmdlineScanOverlap   = 256
+)
+
+var (
+	ErrInvalidMBR       = errors.New("invalid mbr")
+	ErrInconsistentPart = errors.New("inconsistent disk parts")
+	ErrUnsupportedPart  = errors.New("unsupported disk partition layout")
+	ErrPartTooLarge     = errors.New("disk part does not fit its partition")
+)
+
+// cmdlineRootRegexp matches the root= parameter gokrazy writes to the
+// cmdline.txt of the boot partition.
+var cmdlineRootRegexp = regexp.MustCompile(
+	`root=(?:PARTUUID=(?:` + gptGUIDPrefix + `([0-9a-f]{8})0001/PARTNROFF=([12])|([0-9a-f]{8})-0([23]))|/dev/[a-z0-9]*[a-z]([23]))`)
+
+// cmdlineRoot describes the root= parameter of the boot partition cmdline.txt.
+type cmdlineRoot struct {
+	// offset is the offset of the parameter within the boot partition.
+	offset int64
+
+	// param is the whole root= parameter.
+	param []byte
+
+	// partuuid is the PARTUUID referenced by the parameter, if any.
+	partuuid uint32
+
+	usePartuuid    bool
+	useGPTPartuuid bool
+
+	// partition is the number of the active root partition, 2 or 3.
+	partition int
+}
+
+// Layout describes how a gokrazy disk is partitioned.
+type Layout struct {
+	// GPT is true when the disk uses a hybrid MBR + GPT partition table,
+	// false when the disk uses an MBR only partition table.
+	GPT bool
+
+	// Partuuid is the hostname derived identifier used for the MBR disk signature
+	// and the GPT partition GUIDs.
+	Partuuid uint32
+
+	// UsePartuuid is true when the kernel cmdline references
+	// the root partition by its MBR PARTUUID.
+	UsePartuuid bool
+
+	// UseGPTPartuuid is true when the kernel cmdline references
+	// the root partition by its GPT PARTUUID.
+	UseGPTPartuuid bool
+
+	// BootOffset is the offset of the Boot partition.
+	BootOffset int64
+
+	// RootOffset is the offset of the first Root partition.
+	RootOffset int64
+}
+
+// Pack returns the gokrazy packer configured to reproduce the Layout.
+func (l Layout) Pack() packer.Pack {
+	return packer.Pack{
+		Partuuid:       l.Partuuid,
+		UsePartuuid:    l.UsePartuuid,
+		UseGPTPartuuid: l.UseGPTPartuuid,
+		UseGPT:         l.GPT,
+	}
+}
+
+// DetectLayout inspects the mbr and the boot partition content of a gokrazy disk
+// to find out the partitioning scheme it was built for.
+// The mbr is either the MBR boot code written by gok (446 bytes)
+// or a full MBR sector including the partition table (512 bytes).
+// The gaf metadata (sbom.json) only holds hashes of the build inputs,
+// so the layout is detected from the mbr and the boot partition alone.
+func DetectLayout(mbr []byte, boot io.Reader, hostname string) (Layout, error) {
+	l := Layout{
+		GPT:        true,
+		Partuuid:   hostnamePartuuid(hostname),
+		BootOffset: BootPartitionOffset,
+		RootOffset: RootPartitionOffset,
+	}
+
+	if len(mbr) < mbrBootCodeSize || len(mbr) > sectorSize {
+		return Layout{}, fmt.Errorf("%w: unexpected size of %d bytes (want between %d and %d)",
+			ErrInvalidMBR, len(mbr), mbrBootCodeSize, sectorSize)
+	}
+
+	if len(mbr) == sectorSize {
+		if err := l.readPartitionTable(mbr); err != nil {
+			return Layout{}, err
+		}
+	}
+
+	root, err := findCmdlineRoot(boot)
+	if err != nil {
+		return Layout{}, fmt.Errorf("error reading boot partition cmdline: %w", err)
+	}
+
+	switch {
+	case root == nil:
+		// No cmdline.txt was found: keep the defaults gok uses.
+		l.UsePartuuid = true
+		l.UseGPTPartuuid = l.GPT
+
+	case root.partition != 2:
+		return Layout{}, fmt.Errorf("%w: boot partition cmdline %q references root partition %d, want 2",
+			ErrInconsistentPart, root.param, root.partition)
+
+	case root.useGPTPartuuid && !l.GPT:
+		return Layout{}, fmt.Errorf("%w: cmdline references a GPT PARTUUID but mbr has no GPT protective partition",
+			ErrInconsistentPart)
+
+	case root.usePartuuid:
+		l.UsePartuuid = true
+		l.UseGPTPartuuid = root.useGPTPartuuid
+		l.Partuuid = root.partuuid
+	}
+
+	if want := hostnamePartuuid(hostname); l.Partuuid != want {
+		return Layout{}, fmt.Errorf("%w: boot partition was built for PARTUUID %08x, root partition for %08x (hostname %q)",
+			ErrInconsistentPart, l.Partuuid, want, hostname)
+	}
+
+	if len(mbr) >= mbrBootCodeSize+4 && l.UsePartuuid {
+		sig := binary.LittleEndian.Uint32(mbr[mbrBootCodeSize:])
+		if sig != 0 && sig != l.Partuuid {
+			return Layout{}, fmt.Errorf("%w: mbr disk signature %08x does not match PARTUUID %08x",
+				ErrInconsistentPart, sig, l.Partuuid)
+		}
+	}
+
+	if l.BootOffset != BootPartitionOffset || l.RootOffset != RootPartitionOffset {
+		return Layout{}, fmt.Errorf("%w: boot partition at %d, root partition at %d (want %d, %d)",
+			ErrUnsupportedPart, l.BootOffset, l.RootOffset, BootPartitionOffset, RootPartitionOffset)
+	}
+
+	return l, nil
+}
+
+// readPartitionTable reads the partitioning scheme and the offsets
+// from the partition table of a full MBR sector.
+func (l *Layout) readPartitionTable(mbr []byte) error {
+	if !bytes.Equal(mbr[sectorSize-2:], []byte{0x55, 0xAA}) {
+		return fmt.Errorf("%w: missing boot signature", ErrInvalidMBR)
+	}
+
+	type entry struct {
+		typ      byte
+		firstLBA uint32
+	}
+
+	var entries [4]entry
+	l.GPT = false
+	for i := range entries {
+		e := mbr[mbrPartitionTableOffset+i*mbrPartitionEntrySize:]
+		entries[i] = entry{
+			typ:      e[4],
+			firstLBA: binary.LittleEndian.Uint32(e[8:12]),
+		}
+
+		if entries[i].typ == mbrTypeGPTProtective {
+			l.GPT = true
+		}
+	}
+
+	if entries[0].typ != packer.FAT {
+		return fmt.Errorf("%w: first partition has type %#x, want FAT (%#x)",
+			ErrUnsupportedPart, entries[0].typ, packer.FAT)
+	}
+
+	l.BootOffset = int64(entries[0].firstLBA) * sectorSize
+
+	if !l.GPT {
+		// In MBR only partition tables the second partition is the first Root.
+		if entries[1].typ != packer.SquashFS {
+			return fmt.Errorf("%w: second partition has type %#x, want SquashFS (%#x)",
+				ErrUnsupportedPart, entries[1].typ, packer.SquashFS)
+		}
+
+		l.RootOffset = int64(entries[1].firstLBA) * sectorSize
+	}
+
+	return nil
+}
+
+// findCmdlineRoot looks for the root= parameter of the cmdline.txt
+// stored in the boot partition, returning nil when none is found.
+func findCmdlineRoot(boot io.Reader) (*cmdlineRoot, error) {
+	chunk, m, chunkOffset, err := findRegexp(boot, cmdlineRootRegexp)
+	if err != nil || m == nil {
+		return nil, err
+	}
+
+	return parseCmdlineRoot(chunk, m, chunkOffset)
+}
+
+// findRegexp looks for the first match of re in r, scanning it in chunks.
+// It returns the chunk holding the match, the submatch indexes within it
+// and the offset of the chunk, or a nil match when none is found.
+func findRegexp(r io.Reader, re *regexp.Regexp) ([]byte, []int, int64, error) {
+	buf := make([]byte, cmdlineScanChunkSize+cmdlineScanOverlap)
+	carry := 0
+	var chunkOffset int64
+
+	for {
+		n, err := io.ReadFull(r, buf[carry:])
+		chunk := buf[:carry+n]
+
+		if m := re.FindSubmatchIndex(chunk); m != nil {
+			return chunk, m, chunkOffset, nil
+		}
+
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, nil, 0, nil
+		}
+
+		if err != nil {
+			return nil, nil, 0, err
+		}
+
+		// Keep the tail of the chunk around, in case the match
+		// spans across two chunks.
+		carry = copy(buf, chunk[len(chunk)-cmdlineScanOverlap:])
+		chunkOffset += int64(len(chunk) - carry)
+	}
+}
+
+// parseCmdlineRoot parses the cmdlineRootRegexp submatch indexes m found in chunk.
+func parseCmdlineRoot(chunk []byte, m []int, chunkOffset int64) (*cmdlineRoot, error) {
+	group := func(i int) string {
+		if m[2*i] < 0 {
+			return ""
+		}
+
+		return string(chunk[m[2*i]:m[2*i+1]])
+	}
+
+	root := &cmdlineRoot{
+		offset: chunkOffset + int64(m[0]),
+		param:  append([]byte(nil), chunk[m[0]:m[1]]...),
+	}
+
+	var partuuid, partition string
+	switch {
+	case group(1) != "":
+		root.useGPTPartuuid = true
+		partuuid = group(1)
+		partition = group(2)
+
+	case group(3) != "":
+		partuuid = group(3)
+		partition = group(4)
+
+	default:
+		partition = group(5)
+	}
+
+	if partuuid != "" {
+		p, err := strconv.ParseUint(partuuid, 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing cmdline root PARTUUID: %w", err)
+		}
+
+		root.usePartuuid = true
+		root.partuuid = uint32(p)
+	}
+
+	n, err := strconv.Atoi(partition)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing cmdline root partition: %w", err)
+	}
+
+	root.partition = n
+	if root.useGPTPartuuid {
+		// PARTNROFF is relative to the Boot partition.
+		root.partition++
+	}
+
+	return root, nil
+}
+
+// hostnamePartuuid returns the PARTUUID gokrazy derives from the hostname.
+func hostnamePartuuid(hostname string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(hostname))
+
+	return h.Sum32()
+}
@@ -0,0 +1,143 @@
+package disk
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gokrazy/tools/packer"
+)
+
+// mbrEntry is an entry of the partition table of a test MBR.
+type mbrEntry struct {
+	typ      byte
+	firstLBA uint32
+	sectors  uint32
+}
+
+// testMBR returns a full MBR sector with the disk signature and the partition table entries.
+func testMBR(signature uint32, entries ...mbrEntry) []byte {
+	mbr := make([]byte, sectorSize)
+	binary.LittleEndian.PutUint32(mbr[mbrBootCodeSize:], signature)
+
+	for i, e := range entries {
+		b := mbr[mbrPartitionTableOffset+i*mbrPartitionEntrySize:]
+		b[4] = e.typ
+		binary.LittleEndian.PutUint32(b[8:12], e.firstLBA)
+		binary.LittleEndian.PutUint32(b[12:16], e.sectors)
+	}
+
+	mbr[sectorSize-2], mbr[sectorSize-1] = 0x55, 0xAA
+
+	return mbr
+}
+
+func TestDetectLayout(t *testing.T) {
+	const hostname = "gokrazy"
+	partuuid := hostnamePartuuid(hostname)
+
+	bootLBA := uint32(BootPartitionOffset / sectorSize)
+	rootLBA := uint32(RootPartitionOffset / sectorSize)
+
+	mbrOnly := testMBR(partuuid,
+		mbrEntry{typ: packer.FAT, firstLBA: bootLBA},
+		mbrEntry{typ: packer.SquashFS, firstLBA: rootLBA})
+	hybrid := testMBR(partuuid,
+		mbrEntry{typ: packer.FAT, firstLBA: bootLBA},
+		mbrEntry{typ: mbrTypeGPTProtective, firstLBA: 1})
+
+	tests := []struct {
+		name    string
+		mbr     []byte
+		cmdline string
+		want    Layout
+		wantErr error
+	}{
+		{
+			name: "boot code without cmdline",
+			mbr:  make([]byte, mbrBootCodeSize),
+			want: Layout{GPT: true, Partuuid: partuuid, UsePartuuid: true, UseGPTPartuuid: true},
+		},
+		{
+			name:    "mbr only with device root",
+			mbr:     mbrOnly,
+			cmdline: "console=tty1 root=/dev/mmcblk0p2 init=/gokrazy/init",
+			want:    Layout{Partuuid: partuuid},
+		},
+		{
+			name:    "mbr only with partuuid root",
+			mbr:     mbrOnly,
+			cmdline: fmt.Sprintf("root=PARTUUID=%08x-02", partuuid),
+			want:    Layout{Partuuid: partuuid, UsePartuuid: true},
+		},
+		{
+			name:    "hybrid with gpt partuuid root",
+			mbr:     hybrid,
+			cmdline: fmt.Sprintf("root=PARTUUID=%s%08x0001/PARTNROFF=1", gptGUIDPrefix, partuuid),
+			want:    Layout{GPT: true, Partuuid: partuuid, UsePartuuid: true, UseGPTPartuuid: true},
+		},
+		{
+			name:    "too short mbr",
+			mbr:     make([]byte, 100),
+			wantErr: ErrInvalidMBR,
+		},
+		{
+			name:    "full mbr without boot signature",
+			mbr:     make([]byte, sectorSize),
+			wantErr: ErrInvalidMBR,
+		},
+		{
+			name:    "first partition not FAT",
+			mbr:     testMBR(partuuid, mbrEntry{typ: packer.SquashFS, firstLBA: bootLBA}),
+			wantErr: ErrUnsupportedPart,
+		},
+		{
+			name:    "boot partition at an unexpected offset",
+			mbr:     testMBR(partuuid, mbrEntry{typ: packer.FAT, firstLBA: 2048}, mbrEntry{typ: mbrTypeGPTProtective}),
+			wantErr: ErrUnsupportedPart,
+		},
+		{
+			name:    "cmdline referencing the second root partition",
+			mbr:     mbrOnly,
+			cmdline: "root=/dev/sda3",
+			wantErr: ErrInconsistentPart,
+		},
+		{
+			name:    "cmdline built for another hostname",
+			mbr:     mbrOnly,
+			cmdline: fmt.Sprintf("root=PARTUUID=%08x-02", hostnamePartuuid("other")),
+			wantErr: ErrInconsistentPart,
+		},
+		{
+			name:    "gpt partuuid without gpt",
+			mbr:     mbrOnly,
+			cmdline: fmt.Sprintf("root=PARTUUID=%s%08x0001/PARTNROFF=1", gptGUIDPrefix, partuuid),
+			wantErr: ErrInconsistentPart,
+		},
+		{
+			name:    "mbr disk signature not matching the partuuid",
+			mbr:     testMBR(hostnamePartuuid("other"), mbrEntry{typ: packer.FAT, firstLBA: bootLBA}, mbrEntry{typ: mbrTypeGPTProtective}),
+			wantErr: ErrInconsistentPart,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DetectLayout(tt.mbr, strings.NewReader(tt.cmdline), hostname)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DetectLayout() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr != nil {
+				return
+			}
+
+			tt.want.BootOffset, tt.want.RootOffset = BootPartitionOffset, RootPartitionOffset
+			if got != tt.want {
+				t.Errorf("DetectLayout() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package disk
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+)
+
+const mkfsCmd = "mkfs.ext4"
+
+var ErrPermTooLarge = errors.New("perm file does not fit the perm partition")
+
+var permFilePermission os.FileMode = 0600
+
+// PermSize returns the size of the Perm partition of a disk of diskSize bytes,
+// leaving room for the secondary GPT header like gok does.
+func PermSize(diskSize int64) int64 {
+	return diskSize - PermPartitionOffset - gptBackupSize - sectorSize
+}
+
+// CreatePerm creates a perm file of size bytes at permPath, formatted as ext4.
+func CreatePerm(ctx context.Context, permPath string, size int64) error {
+	if _, err := exec.LookPath(mkfsCmd); err != nil {
+		return fmt.Errorf("error while looking for %s executable, is e2fsprogs installed?: %w", mkfsCmd, err)
+	}
+
+	f, err := os.OpenFile(permPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, permFilePermission)
+	if err != nil {
+		return fmt.Errorf("error creating perm file %s: %w", permPath, err)
+	}
+	defer f.Close()
+
+	if err := f.Truncate(size); err != nil {
+		return fmt.Errorf("error preparing perm file: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("error closing perm file: %w", err)
+	}
+
+	var stderr bytes.Buffer
+	mkfs := exec.CommandContext(ctx, mkfsCmd, "-q", "-F", permPath)
+	mkfs.Stderr = &stderr
+
+	if err := mkfs.Run(); err != nil {
+		os.Remove(permPath)
+
+		return fmt.Errorf("error formatting perm file: %v: %w: %s", mkfs.Args, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	return nil
+}
+
+// SplicePerm writes the content of the perm file to the Perm partition of the disk file.
+func SplicePerm(diskPath, permPath string) error {
+	diskFile, err := os.OpenFile(diskPath, os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("error opening disk file %s: %w", diskPath, err)
+	}
+	defer diskFile.Close()
+
+	fi, err := diskFile.Stat()
+	if err != nil {
+		return fmt.Errorf("error getting disk file info: %w", err)
+	}
+
+	permFile, err := os.Open(permPath)
+	if err != nil {
+		return fmt.Errorf("error opening perm file %s: %w", permPath, err)
+	}
+	defer permFile.Close()
+
+	permInfo, err := permFile.Stat()
+	if err != nil {
+		return fmt.Errorf("error getting perm file info: %w", err)
+	}
+
+	if size := PermSize(fi.Size()); permInfo.Size() > size {
+		return fmt.Errorf("%w: perm file is %d bytes, perm partition is %d bytes, increase the disk size",
+			ErrPermTooLarge, permInfo.Size(), size)
+	}
+
+	if err := SparseCopy(io.NewOffsetWriter(diskFile, PermPartitionOffset), permFile); err != nil {
+		return fmt.Errorf("error writing perm file to disk file: %w", err)
+	}
+
+	return diskFile.Close()
+}
+
+// ExtractPerm writes the content of the Perm partition of the disk file back to the perm file.
+func ExtractPerm(diskPath, permPath string) error {
+	diskFile, err := os.Open(diskPath)
+	if err != nil {
+		return fmt.Errorf("error opening disk file %s: %w", diskPath, err)
+	}
+	defer diskFile.Close()
+
+	permFile, err := os.OpenFile(permPath, os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("error opening perm file %s: %w", permPath, err)
+	}
+	defer permFile.Close()
+
+	permInfo, err := permFile.Stat()
+	if err != nil {
+		return fmt.Errorf("error getting perm file info: %w", err)
+	}
+
+	if _, err := io.Copy(permFile, io.NewSectionReader(diskFile, PermPartitionOffset, permInfo.Size())); err != nil {
+		return fmt.Errorf("error writing perm partition to perm file: %w", err)
+	}
+
+	return permFile.Close()
+}
@@ -0,0 +1,64 @@
+package disk
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DefaultDiskSize is the size of the assembled disk when none is specified.
+	DefaultDiskSize = 2 * 1024 * mb
+
+	// gptBackupSize is the space needed at the end of the disk by the secondary GPT header.
+	gptBackupSize = 33 * sectorSize
+
+	// minPermPartitionSize is the smallest Perm partition gokrazy can make use of.
+	minPermPartitionSize = 16 * mb
+
+	// MinDiskSize is the smallest disk that can hold the Boot, both Root and the Perm partition.
+	MinDiskSize = PermPartitionOffset + minPermPartitionSize + gptBackupSize
+)
+
+var (
+	ErrInvalidDiskSize  = errors.New("invalid disk size")
+	ErrDiskSizeTooSmall = errors.New("disk size too small")
+)
+
+// ParseSize parses a size in bytes, with an optional suffix
+// k, M, G, T, P or E meaning kibi-, mebi-, gibi-, tebi-, pebi- and exbibytes, respectively.
+func ParseSize(s string) (int64, error) {
+	multiplier := int64(1)
+
+	if i := strings.IndexAny(s, "kKMGTPE"); i > -1 && i == len(s)-1 {
+		multiplier = int64(1) << (10 * (strings.IndexByte("KMGTPE", strings.ToUpper(s[i:])[0]) + 1))
+		s = s[:i]
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidDiskSize, s)
+	}
+
+	if n > (1<<63-1)/multiplier {
+		return 0, fmt.Errorf("%w: %q overflows", ErrInvalidDiskSize, s)
+	}
+
+	return n * multiplier, nil
+}
+
+// ValidateSize checks the disk size is usable for a gokrazy disk.
+func ValidateSize(size int64) error {
+	if size%sectorSize != 0 {
+		return fmt.Errorf("%w: %d bytes is not a multiple of the %d bytes sector size",
+			ErrInvalidDiskSize, size, sectorSize)
+	}
+
+	if size < MinDiskSize {
+		return fmt.Errorf("%w: %d bytes (at least %d MiB needed for boot, root and perm partitions)",
+			ErrDiskSizeTooSmall, size, (MinDiskSize+mb-1)/mb)
+	}
+
+	return nil
+}
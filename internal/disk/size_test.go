@@ -0,0 +1,44 @@
+package disk
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr error
+	}{
+		{in: "512", want: 512},
+		{in: "1k", want: 1024},
+		{in: "1K", want: 1024},
+		{in: "1121M", want: 1121 * mb},
+		{in: "2G", want: 2 * 1024 * mb},
+		{in: "1T", want: 1 << 40},
+		{in: "1P", want: 1 << 50},
+		{in: "7E", want: 7 << 60},
+		{in: "8E", wantErr: ErrInvalidDiskSize},
+		{in: "", wantErr: ErrInvalidDiskSize},
+		{in: "G", wantErr: ErrInvalidDiskSize},
+		{in: "0", wantErr: ErrInvalidDiskSize},
+		{in: "-1G", wantErr: ErrInvalidDiskSize},
+		{in: "1.5G", wantErr: ErrInvalidDiskSize},
+		{in: "1GB", wantErr: ErrInvalidDiskSize},
+		{in: "1m", wantErr: ErrInvalidDiskSize},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseSize(tt.in)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseSize(%q) error = %v, want %v", tt.in, err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("ParseSize(%q) = %d, want %d", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package disk
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// sparseBlockSize is the size of the blocks SparseCopy skips when zeroed.
+const sparseBlockSize = 64 * 1024
+
+// SparseCopy copies source to dest, skipping its zeroed blocks,
+// which are expected to be zero in the destination too
+// (e.g. holes of a file truncated to the size of the source).
+func SparseCopy(dest io.WriterAt, source io.Reader) error {
+	buf := make([]byte, sparseBlockSize)
+	zero := make([]byte, sparseBlockSize)
+
+	for offset := int64(0); ; {
+		n, err := io.ReadFull(source, buf)
+		if n > 0 && !bytes.Equal(buf[:n], zero[:n]) {
+			if _, err := dest.WriteAt(buf[:n], offset); err != nil {
+				return fmt.Errorf("error writing sparse copy: %w", err)
+			}
+		}
+		offset += int64(n)
+
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("error reading sparse copy source: %w", err)
+		}
+	}
+}
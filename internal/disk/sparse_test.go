@@ -0,0 +1,58 @@
+package disk
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"testing/iotest"
+)
+
+// recordingWriterAt is an in memory io.WriterAt recording the offsets written.
+type recordingWriterAt struct {
+	buf     []byte
+	offsets []int64
+}
+
+func (w *recordingWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	w.offsets = append(w.offsets, off)
+
+	return copy(w.buf[off:], p), nil
+}
+
+func TestSparseCopy(t *testing.T) {
+	const size = 4*sparseBlockSize + 100
+
+	source := make([]byte, size)
+	source[10] = 1                   // first block
+	source[2*sparseBlockSize+1] = 2  // third block
+	source[4*sparseBlockSize+99] = 3 // trailing partial block
+
+	dest := &recordingWriterAt{buf: make([]byte, size)}
+	if err := SparseCopy(dest, bytes.NewReader(source)); err != nil {
+		t.Fatalf("SparseCopy() = %v, want nil", err)
+	}
+
+	if !bytes.Equal(dest.buf, source) {
+		t.Error("SparseCopy() destination differs from the source")
+	}
+
+	want := []int64{0, 2 * sparseBlockSize, 4 * sparseBlockSize}
+	if len(dest.offsets) != len(want) {
+		t.Fatalf("SparseCopy() wrote at %v, want %v", dest.offsets, want)
+	}
+
+	for i := range want {
+		if dest.offsets[i] != want[i] {
+			t.Fatalf("SparseCopy() wrote at %v, want %v", dest.offsets, want)
+		}
+	}
+}
+
+func TestSparseCopyReadError(t *testing.T) {
+	errRead := errors.New("read error")
+
+	dest := &recordingWriterAt{buf: make([]byte, sparseBlockSize)}
+	if err := SparseCopy(dest, iotest.ErrReader(errRead)); !errors.Is(err, errRead) {
+		t.Errorf("SparseCopy() = %v, want %v", err, errRead)
+	}
+}
@@ -0,0 +1,193 @@
+package disk
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+
+	"github.com/CalebQ42/squashfs"
+)
+
+const (
+	bootPartitionNumber = 1
+	rootPartitionNumber = 2
+
+	// squashfsBlockSize is the size squashfs images are padded to.
+	squashfsBlockSize = 4096
+
+	// SBOMPath is the path of the SBOM within the root squashfs.
+	SBOMPath = "etc/gokrazy/sbom.json"
+)
+
+var ErrMissingPartition = errors.New("missing partition")
+
+var partFilePermission fs.FileMode = 0644
+
+// FullToParts splits a full disk image file into multi parts (mbr, boot, root) image files.
+// The active Root partition is the one written to rootDestPath.
+// If the root contains an SBOM, it is written to sbomDestPath;
+// the returned bool reports whether it was found.
+func FullToParts(sourcePath, mbrDestPath, bootDestPath, rootDestPath, sbomDestPath string) (bool, error) {
+	f, err := os.Open(sourcePath)
+	if err != nil {
+		return false, fmt.Errorf("error opening full disk file %s: %w", sourcePath, err)
+	}
+	defer f.Close()
+
+	table, err := ReadPartitionTable(f)
+	if err != nil {
+		return false, fmt.Errorf("error reading full disk partition table: %w", err)
+	}
+
+	bootPart, ok := table.Partition(bootPartitionNumber)
+	if !ok {
+		return false, fmt.Errorf("%w: boot partition %d", ErrMissingPartition, bootPartitionNumber)
+	}
+
+	// MBR.
+	mbr := make([]byte, sectorSize)
+	if _, err := f.ReadAt(mbr, MBRPartitionOffset); err != nil {
+		return false, fmt.Errorf("error reading mbr: %w", err)
+	}
+
+	if err := os.WriteFile(mbrDestPath, mbr, partFilePermission); err != nil {
+		return false, fmt.Errorf("error writing mbr partition file %s: %w", mbrDestPath, err)
+	}
+
+	// Boot.
+	boot := io.NewSectionReader(f, bootPart.Offset, bootPart.Size)
+
+	root, err := findCmdlineRoot(boot)
+	if err != nil {
+		return false, fmt.Errorf("error reading boot partition cmdline: %w", err)
+	}
+
+	activeRoot := rootPartitionNumber
+	if root != nil {
+		activeRoot = root.partition
+	}
+
+	rootPart, ok := table.Partition(activeRoot)
+	if !ok {
+		return false, fmt.Errorf("%w: active root partition %d", ErrMissingPartition, activeRoot)
+	}
+
+	bootSize := fatSize(boot)
+	if bootSize <= 0 || bootSize > bootPart.Size {
+		bootSize = bootPart.Size
+	}
+
+	if err := writeSection(f, bootPart.Offset, bootSize, bootDestPath); err != nil {
+		return false, fmt.Errorf("error writing boot partition file: %w", err)
+	}
+
+	if root != nil && root.partition != rootPartitionNumber {
+		// The root is split out as the first Root partition,
+		// point the cmdline to it. The partition number is always
+		// the last character of the parameter.
+		if err := patchByte(bootDestPath, root.offset+int64(len(root.param))-1,
+			root.param[len(root.param)-1]-byte(root.partition-rootPartitionNumber)); err != nil {
+			return false, fmt.Errorf("error updating boot partition cmdline root: %w", err)
+		}
+	}
+
+	// Root.
+	rootSection := io.NewSectionReader(f, rootPart.Offset, rootPart.Size)
+
+	rootSize := squashfsSize(rootSection)
+	if rootSize <= 0 || rootSize > rootPart.Size {
+		rootSize = rootPart.Size
+	}
+
+	if err := writeSection(f, rootPart.Offset, rootSize, rootDestPath); err != nil {
+		return false, fmt.Errorf("error writing root partition file: %w", err)
+	}
+
+	// SBOM.
+	rd, err := squashfs.NewReader(rootSection)
+	if err != nil {
+		return false, fmt.Errorf("error reading root partition squashFS: %w", err)
+	}
+
+	sbom, err := rd.ReadFile(SBOMPath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("error reading root squashFS SBOM file %q: %w", SBOMPath, err)
+	}
+
+	if err := os.WriteFile(sbomDestPath, sbom, partFilePermission); err != nil {
+		return false, fmt.Errorf("error writing sbom file %s: %w", sbomDestPath, err)
+	}
+
+	return true, nil
+}
+
+// writeSection writes size bytes at offset of the source to the file at destPath.
+func writeSection(source io.ReaderAt, offset, size int64, destPath string) error {
+	dest, err := os.Create(destPath)
+	if err != nil {
+		return fmt.Errorf("error creating file %s: %w", destPath, err)
+	}
+	defer dest.Close()
+
+	if _, err := io.Copy(dest, io.NewSectionReader(source, offset, size)); err != nil {
+		return fmt.Errorf("error writing file %s: %w", destPath, err)
+	}
+
+	return dest.Close()
+}
+
+// patchByte overwrites the byte at offset of the file at path.
+func patchByte(path string, offset int64, b byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("error opening file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteAt([]byte{b}, offset); err != nil {
+		return fmt.Errorf("error writing file %s: %w", path, err)
+	}
+
+	return f.Close()
+}
+
+// fatSize returns the size of the FAT filesystem read from its boot sector,
+// or 0 if it can't be determined.
+func fatSize(r io.ReaderAt) int64 {
+	bpb := make([]byte, sectorSize)
+	if _, err := r.ReadAt(bpb, 0); err != nil {
+		return 0
+	}
+
+	bytesPerSector := int64(binary.LittleEndian.Uint16(bpb[11:13]))
+	sectors := int64(binary.LittleEndian.Uint16(bpb[19:21]))
+	if sectors == 0 {
+		sectors = int64(binary.LittleEndian.Uint32(bpb[32:36]))
+	}
+
+	return bytesPerSector * sectors
+}
+
+// squashfsSize returns the size of the squashfs image read from its superblock,
+// padded like mksquashfs does, or 0 if it can't be determined.
+func squashfsSize(r io.ReaderAt) int64 {
+	superblock := make([]byte, 48)
+	if _, err := r.ReadAt(superblock, 0); err != nil {
+		return 0
+	}
+
+	if string(superblock[:4]) != "hsqs" {
+		return 0
+	}
+
+	bytesUsed := int64(binary.LittleEndian.Uint64(superblock[40:48]))
+
+	return (bytesUsed + squashfsBlockSize - 1) / squashfsBlockSize * squashfsBlockSize
+}
@@ -0,0 +1,194 @@
+package disk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf16"
+)
+
+const (
+	gptHeaderLBA     = 1
+	gptSignature     = "EFI PART"
+	gptMaxEntries    = 128
+	gptMinEntrySize  = 128
+	gptEntryNameSize = 72
+)
+
+var ErrInvalidPartitionTable = errors.New("invalid partition table")
+
+// Partition describes a partition of a disk.
+type Partition struct {
+	// Number is the 1-indexed number of the partition.
+	Number int `json:"number"`
+
+	// Offset is the offset of the partition from the start of the disk.
+	Offset int64 `json:"offset"`
+
+	// Size is the size of the partition.
+	Size int64 `json:"size"`
+
+	// Type is the MBR partition type (e.g. 0x0c) or the GPT partition type GUID.
+	Type string `json:"type"`
+
+	// UUID is the PARTUUID of the partition.
+	UUID string `json:"uuid"`
+
+	// Name is the GPT partition name, empty for MBR partitions.
+	Name string `json:"name,omitempty"`
+}
+
+// PartitionTable describes the partition table of a disk.
+type PartitionTable struct {
+	// GPT is true when the disk uses a GPT partition table.
+	GPT bool `json:"gpt"`
+
+	// DiskID is the GPT disk GUID or the MBR disk signature.
+	DiskID string `json:"disk_id"`
+
+	// Partitions holds the used partitions of the disk.
+	Partitions []Partition `json:"partitions"`
+}
+
+// Partition returns the partition numbered n, if any.
+func (t PartitionTable) Partition(n int) (Partition, bool) {
+	for _, p := range t.Partitions {
+		if p.Number == n {
+			return p, true
+		}
+	}
+
+	return Partition{}, false
+}
+
+// ReadPartitionTable reads the partition table of a disk,
+// preferring the GPT one for hybrid MBR + GPT disks.
+func ReadPartitionTable(r io.ReaderAt) (PartitionTable, error) {
+	mbr := make([]byte, sectorSize)
+	if _, err := r.ReadAt(mbr, 0); err != nil {
+		return PartitionTable{}, fmt.Errorf("error reading mbr: %w", err)
+	}
+
+	if !bytes.Equal(mbr[sectorSize-2:], []byte{0x55, 0xAA}) {
+		return PartitionTable{}, fmt.Errorf("%w: missing mbr boot signature", ErrInvalidPartitionTable)
+	}
+
+	t := PartitionTable{
+		DiskID: fmt.Sprintf("%08x", binary.LittleEndian.Uint32(mbr[mbrBootCodeSize:])),
+	}
+
+	for i := 0; i < 4; i++ {
+		e := mbr[mbrPartitionTableOffset+i*mbrPartitionEntrySize:]
+		typ := e[4]
+		firstLBA := binary.LittleEndian.Uint32(e[8:12])
+		sectors := binary.LittleEndian.Uint32(e[12:16])
+
+		if typ == mbrTypeGPTProtective {
+			return readGPT(r)
+		}
+
+		if typ == 0 {
+			continue
+		}
+
+		t.Partitions = append(t.Partitions, Partition{
+			Number: i + 1,
+			Offset: int64(firstLBA) * sectorSize,
+			Size:   int64(sectors) * sectorSize,
+			Type:   fmt.Sprintf("%#02x", typ),
+			UUID:   fmt.Sprintf("%s-%02x", t.DiskID, i+1),
+		})
+	}
+
+	return t, nil
+}
+
+func readGPT(r io.ReaderAt) (PartitionTable, error) {
+	header := make([]byte, sectorSize)
+	if _, err := r.ReadAt(header, gptHeaderLBA*sectorSize); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return PartitionTable{}, fmt.Errorf("%w: truncated GPT header", ErrInvalidPartitionTable)
+		}
+
+		return PartitionTable{}, fmt.Errorf("error reading GPT header: %w", err)
+	}
+
+	if string(header[:8]) != gptSignature {
+		return PartitionTable{}, fmt.Errorf("%w: missing GPT header signature", ErrInvalidPartitionTable)
+	}
+
+	entriesStart := binary.LittleEndian.Uint64(header[72:80])
+	entriesCount := binary.LittleEndian.Uint32(header[80:84])
+	entrySize := binary.LittleEndian.Uint32(header[84:88])
+
+	if entriesCount > gptMaxEntries || entrySize < gptMinEntrySize || entrySize > sectorSize {
+		return PartitionTable{}, fmt.Errorf("%w: unexpected GPT header with %d entries of %d bytes",
+			ErrInvalidPartitionTable, entriesCount, entrySize)
+	}
+
+	entries := make([]byte, int64(entriesCount)*int64(entrySize))
+	if _, err := r.ReadAt(entries, int64(entriesStart)*sectorSize); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return PartitionTable{}, fmt.Errorf("%w: truncated GPT partition entries", ErrInvalidPartitionTable)
+		}
+
+		return PartitionTable{}, fmt.Errorf("error reading GPT partition entries: %w", err)
+	}
+
+	t := PartitionTable{
+		GPT:    true,
+		DiskID: formatGUID(header[56:72]),
+	}
+
+	for i := 0; i < int(entriesCount); i++ {
+		e := entries[i*int(entrySize):]
+		if bytes.Equal(e[:16], make([]byte, 16)) {
+			continue
+		}
+
+		firstLBA := binary.LittleEndian.Uint64(e[32:40])
+		lastLBA := binary.LittleEndian.Uint64(e[40:48])
+
+		if lastLBA < firstLBA {
+			return PartitionTable{}, fmt.Errorf("%w: GPT partition entry %d ends before it starts",
+				ErrInvalidPartitionTable, i+1)
+		}
+
+		t.Partitions = append(t.Partitions, Partition{
+			Number: i + 1,
+			Offset: int64(firstLBA) * sectorSize,
+			Size:   int64(lastLBA-firstLBA+1) * sectorSize,
+			Type:   formatGUID(e[0:16]),
+			UUID:   formatGUID(e[16:32]),
+			Name:   partitionName(e[56 : 56+gptEntryNameSize]),
+		})
+	}
+
+	return t, nil
+}
+
+// formatGUID formats a GUID stored in the mixed endian GPT format.
+func formatGUID(b []byte) string {
+	return fmt.Sprintf("%08x-%04x-%04x-%x-%x",
+		binary.LittleEndian.Uint32(b[0:4]),
+		binary.LittleEndian.Uint16(b[4:6]),
+		binary.LittleEndian.Uint16(b[6:8]),
+		b[8:10],
+		b[10:16])
+}
+
+// partitionName decodes an UTF16LE GPT partition name.
+func partitionName(b []byte) string {
+	u := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		c := binary.LittleEndian.Uint16(b[i:])
+		if c == 0 {
+			break
+		}
+		u = append(u, c)
+	}
+
+	return string(utf16.Decode(u))
+}
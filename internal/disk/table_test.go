@@ -0,0 +1,163 @@
+package disk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/gokrazy/tools/packer"
+)
+
+// gptEntry is an entry of the partition table of a test GPT disk.
+type gptEntry struct {
+	typ, uuid         [16]byte
+	firstLBA, lastLBA uint64
+	name              string
+}
+
+// testGPT returns a disk with a protective MBR and a GPT header
+// declaring count entries of entrySize bytes, followed by the entries.
+func testGPT(count, entrySize uint32, entries ...gptEntry) []byte {
+	disk := testMBR(0, mbrEntry{typ: mbrTypeGPTProtective, firstLBA: 1})
+
+	header := make([]byte, sectorSize)
+	copy(header, gptSignature)
+	copy(header[56:72], guid(0xd0))
+	binary.LittleEndian.PutUint64(header[72:80], 2)
+	binary.LittleEndian.PutUint32(header[80:84], count)
+	binary.LittleEndian.PutUint32(header[84:88], entrySize)
+	disk = append(disk, header...)
+
+	for _, e := range entries {
+		b := make([]byte, gptMinEntrySize)
+		copy(b[0:16], e.typ[:])
+		copy(b[16:32], e.uuid[:])
+		binary.LittleEndian.PutUint64(b[32:40], e.firstLBA)
+		binary.LittleEndian.PutUint64(b[40:48], e.lastLBA)
+
+		for i, c := range utf16.Encode([]rune(e.name)) {
+			binary.LittleEndian.PutUint16(b[56+2*i:], c)
+		}
+
+		disk = append(disk, b...)
+	}
+
+	return disk
+}
+
+// guid returns 16 consecutive bytes starting at first.
+func guid(first byte) []byte {
+	b := make([]byte, 16)
+	for i := range b {
+		b[i] = first + byte(i)
+	}
+
+	return b
+}
+
+func TestReadPartitionTable(t *testing.T) {
+	var bootEntry gptEntry
+	copy(bootEntry.typ[:], guid(0x01))
+	copy(bootEntry.uuid[:], guid(0x11))
+	bootEntry.firstLBA, bootEntry.lastLBA, bootEntry.name = 8192, 8192+204799, "boot"
+
+	backwards := bootEntry
+	backwards.lastLBA = backwards.firstLBA - 1
+
+	tests := []struct {
+		name    string
+		disk    []byte
+		want    PartitionTable
+		wantErr error
+	}{
+		{
+			name: "mbr",
+			disk: testMBR(0x2a,
+				mbrEntry{typ: packer.FAT, firstLBA: 8192, sectors: 204800},
+				mbrEntry{typ: packer.SquashFS, firstLBA: 212992, sectors: 409600}),
+			want: PartitionTable{
+				DiskID: "0000002a",
+				Partitions: []Partition{
+					{Number: 1, Offset: 8192 * sectorSize, Size: 204800 * sectorSize, Type: "0x0c", UUID: "0000002a-01"},
+					{Number: 2, Offset: 212992 * sectorSize, Size: 409600 * sectorSize, Type: "0x83", UUID: "0000002a-02"},
+				},
+			},
+		},
+		{
+			name: "gpt",
+			disk: testGPT(2, gptMinEntrySize, bootEntry, gptEntry{}),
+			want: PartitionTable{
+				GPT:    true,
+				DiskID: "d3d2d1d0-d5d4-d7d6-d8d9-dadbdcdddedf",
+				Partitions: []Partition{{
+					Number: 1,
+					Offset: 8192 * sectorSize,
+					Size:   204800 * sectorSize,
+					Type:   "04030201-0605-0807-090a-0b0c0d0e0f10",
+					UUID:   "14131211-1615-1817-191a-1b1c1d1e1f20",
+					Name:   "boot",
+				}},
+			},
+		},
+		{
+			name:    "missing mbr boot signature",
+			disk:    make([]byte, sectorSize),
+			wantErr: ErrInvalidPartitionTable,
+		},
+		{
+			name:    "missing gpt header signature",
+			disk:    append(testMBR(0, mbrEntry{typ: mbrTypeGPTProtective, firstLBA: 1}), make([]byte, sectorSize)...),
+			wantErr: ErrInvalidPartitionTable,
+		},
+		{
+			name:    "gpt header truncated by the end of the image",
+			disk:    testMBR(0, mbrEntry{typ: mbrTypeGPTProtective, firstLBA: 1}),
+			wantErr: ErrInvalidPartitionTable,
+		},
+		{
+			name:    "gpt entry size overflowing the entries size",
+			disk:    testGPT(2, 0x80000000, bootEntry),
+			wantErr: ErrInvalidPartitionTable,
+		},
+		{
+			name:    "gpt entry size too small",
+			disk:    testGPT(2, 64, bootEntry),
+			wantErr: ErrInvalidPartitionTable,
+		},
+		{
+			name:    "too many gpt entries",
+			disk:    testGPT(gptMaxEntries+1, gptMinEntrySize, bootEntry),
+			wantErr: ErrInvalidPartitionTable,
+		},
+		{
+			name:    "gpt partition ending before it starts",
+			disk:    testGPT(1, gptMinEntrySize, backwards),
+			wantErr: ErrInvalidPartitionTable,
+		},
+		{
+			name:    "gpt entries truncated by the end of the image",
+			disk:    testGPT(4, gptMinEntrySize, bootEntry),
+			wantErr: ErrInvalidPartitionTable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadPartitionTable(bytes.NewReader(tt.disk))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ReadPartitionTable() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr != nil {
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadPartitionTable() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package gaf
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"sort"
+	"strings"
+)
+
+// ErrChecksumMismatch denotes the error for a gaf member
+// whose content doesn't match its checksum.
+var ErrChecksumMismatch = errors.New("gaf member checksum mismatch")
+
+// ChecksumError is returned when reading a gaf member
+// whose content doesn't match the checksums manifest.
+type ChecksumError struct {
+	// Member is the name of the corrupt gaf member.
+	Member string
+
+	// Want is the hex encoded SHA256 declared in the checksums manifest.
+	Want string
+
+	// Got is the hex encoded SHA256 of the member content.
+	Got string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("%s: %s: want sha256 %s, got %s", ErrChecksumMismatch, e.Member, e.Want, e.Got)
+}
+
+func (e *ChecksumError) Is(target error) bool {
+	return target == ErrChecksumMismatch //nolint:goerr113
+}
+
+// checksummedMembers are the gaf members the checksums manifest must list, and the only ones it can list.
+var checksummedMembers = []string{MBR, Boot, Root, SBOM}
+
+// parseChecksums parses a checksums manifest in the sha256sum format,
+// listing each of the checksummed members exactly once.
+func parseChecksums(r io.Reader) (map[string]string, error) {
+	checksums := make(map[string]string)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		sum, name, ok := strings.Cut(line, " ")
+		name = strings.TrimPrefix(strings.TrimSpace(name), "*")
+
+		if _, err := hex.DecodeString(sum); !ok || err != nil || len(sum) != 2*sha256.Size || name == "" {
+			return nil, fmt.Errorf("%w: invalid %s line %q", ErrMaformedGaf, Checksums, line)
+		}
+
+		if !isChecksummedMember(name) {
+			return nil, fmt.Errorf("%w: unknown member %q in %s", ErrMaformedGaf, name, Checksums)
+		}
+
+		if _, ok := checksums[name]; ok {
+			return nil, fmt.Errorf("%w: duplicate member %q in %s", ErrMaformedGaf, name, Checksums)
+		}
+
+		checksums[name] = strings.ToLower(sum)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", Checksums, err)
+	}
+
+	for _, name := range checksummedMembers {
+		if _, ok := checksums[name]; !ok {
+			return nil, fmt.Errorf("%w: member %q missing from %s", ErrMaformedGaf, name, Checksums)
+		}
+	}
+
+	return checksums, nil
+}
+
+func isChecksummedMember(name string) bool {
+	for _, member := range checksummedMembers {
+		if name == member {
+			return true
+		}
+	}
+
+	return false
+}
+
+// formatChecksums formats a checksums manifest in the sha256sum format.
+func formatChecksums(checksums map[string]string) []byte {
+	names := make([]string, 0, len(checksums))
+	for name := range checksums {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s  %s\n", checksums[name], name)
+	}
+
+	return []byte(b.String())
+}
+
+// verifyingReadCloser hashes the content read through it,
+// returning a ChecksumError instead of io.EOF if it doesn't match.
+type verifyingReadCloser struct {
+	io.ReadCloser
+	member string
+	want   string
+	hash   hash.Hash
+}
+
+func newVerifyingReadCloser(rc io.ReadCloser, member, want string) *verifyingReadCloser {
+	return &verifyingReadCloser{ReadCloser: rc, member: member, want: want, hash: sha256.New()}
+}
+
+func (v *verifyingReadCloser) Read(p []byte) (int, error) {
+	n, err := v.ReadCloser.Read(p)
+	v.hash.Write(p[:n])
+
+	if errors.Is(err, io.EOF) {
+		if got := hex.EncodeToString(v.hash.Sum(nil)); got != v.want {
+			return n, &ChecksumError{Member: v.member, Want: v.want, Got: got}
+		}
+	}
+
+	return n, err
+}
@@ -0,0 +1,86 @@
+package gaf
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseChecksums(t *testing.T) {
+	sum := func(c string) string { return strings.Repeat(c, 64) }
+
+	all := map[string]string{MBR: sum("1"), Boot: sum("2"), Root: sum("3"), SBOM: sum("4")}
+	manifest := string(formatChecksums(all))
+
+	tests := []struct {
+		name     string
+		manifest string
+		want     map[string]string
+		wantErr  error
+	}{
+		{
+			name:     "all members",
+			manifest: manifest,
+			want:     all,
+		},
+		{
+			name: "binary mode, upper case and blank lines",
+			manifest: sum("A") + " *" + MBR + "\n\n" + sum("2") + "  " + Boot + "\r\n" +
+				sum("3") + "  " + Root + "\n" + sum("4") + "  " + SBOM,
+			want: map[string]string{MBR: sum("a"), Boot: sum("2"), Root: sum("3"), SBOM: sum("4")},
+		},
+		{
+			name:     "missing member",
+			manifest: sum("1") + "  " + MBR + "\n",
+			wantErr:  ErrMaformedGaf,
+		},
+		{
+			name:     "unknown member",
+			manifest: manifest + sum("5") + "  perm.img\n",
+			wantErr:  ErrMaformedGaf,
+		},
+		{
+			name:     "duplicate member",
+			manifest: manifest + sum("5") + "  " + Root + "\n",
+			wantErr:  ErrMaformedGaf,
+		},
+		{
+			name:     "short checksum",
+			manifest: strings.Replace(manifest, sum("1"), "1111", 1),
+			wantErr:  ErrMaformedGaf,
+		},
+		{
+			name:     "non hex checksum",
+			manifest: strings.Replace(manifest, sum("1"), sum("z"), 1),
+			wantErr:  ErrMaformedGaf,
+		},
+		{
+			name:     "missing name",
+			manifest: sum("1") + "\n",
+			wantErr:  ErrMaformedGaf,
+		},
+		{
+			name:     "empty",
+			manifest: "",
+			wantErr:  ErrMaformedGaf,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseChecksums(strings.NewReader(tt.manifest))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("parseChecksums() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr != nil {
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseChecksums() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,11 +3,18 @@ package gaf
 import (
 	"archive/zip"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
+	"time"
 )
 
+// ErrMissingContent denotes the error for failed creation of a gaf
+// due to missing content.
+var ErrMissingContent = errors.New("unable to create gaf with missing content")
+
 // ErrMaformedGaf denotes the error for failed extraction of a gaf
 // due to it being malformed.
 var ErrMaformedGaf = errors.New("unable to extract malformed gaf")
@@ -20,62 +27,194 @@ type ReadClosers struct {
 	SBOMRC io.ReadCloser
 }
 
+// Readers holds Readers for the content of a gaf file.
+type Readers struct {
+	MBR  io.Reader
+	Boot io.Reader
+	Root io.Reader
+	SBOM io.Reader
+}
+
 const (
 	MBR  string = "mbr.img"
 	Boot string = "boot.img"
 	Root string = "root.img"
 	SBOM string = "sbom.json"
+
+	// Checksums is the optional manifest holding the SHA256 of the other members,
+	// in the sha256sum format.
+	Checksums string = "checksums.sha256"
 )
 
 // Extract extracts the content of a gaf archive.
+// If the archive holds a checksums manifest, the content is verified while it is read,
+// and reading a member to its end returns a *ChecksumError if it is corrupt.
 func Extract(_ context.Context, source io.ReaderAt, size int64) (ReadClosers, error) {
+	files, checksums, err := members(source, size)
+	if err != nil {
+		return ReadClosers{}, err
+	}
+
 	gafRCs := ReadClosers{}
+	for _, member := range []struct {
+		name string
+		rc   *io.ReadCloser
+	}{
+		{MBR, &gafRCs.MBRRC},
+		{Boot, &gafRCs.BootRC},
+		{Root, &gafRCs.RootRC},
+		{SBOM, &gafRCs.SBOMRC},
+	} {
+		rc, err := openMember(files, checksums, member.name)
+		if err != nil {
+			gafRCs.close()
+
+			return ReadClosers{}, err
+		}
+
+		*member.rc = rc
+	}
+
+	return gafRCs, nil
+}
+
+// ExtractMember extracts the member of a gaf archive with the name, one of MBR, Boot, Root or SBOM,
+// without opening the others. It is verified like the content returned by Extract.
+func ExtractMember(_ context.Context, source io.ReaderAt, size int64, name string) (io.ReadCloser, error) {
+	if !isChecksummedMember(name) {
+		return nil, fmt.Errorf("unknown gaf member %q", name) //nolint:goerr113
+	}
+
+	files, checksums, err := members(source, size)
+	if err != nil {
+		return nil, err
+	}
 
+	return openMember(files, checksums, name)
+}
+
+// members returns the members of a gaf archive described in the gaf spec by name,
+// along with its checksums manifest, nil if it has none.
+func members(source io.ReaderAt, size int64) (map[string]*zip.File, map[string]string, error) {
 	reader, err := zip.NewReader(source, size)
 	if err != nil {
-		return gafRCs, fmt.Errorf("error reading from zip reader: %w", err)
+		return nil, nil, fmt.Errorf("error reading from zip reader: %w", err)
 	}
 
-	// Check the gaf archive contains the files described in the gaf spec.
+	files := make(map[string]*zip.File)
+	var checksums map[string]string
+
 	for _, file := range reader.File {
-		switch file.Name {
-		case MBR:
-			reader, err := file.Open()
-			if err != nil {
-				return ReadClosers{}, err
-			}
-			gafRCs.MBRRC = reader
+		switch {
+		case isChecksummedMember(file.Name):
+			files[file.Name] = file
 
-		case Boot:
-			reader, err := file.Open()
+		case file.Name == Checksums:
+			rc, err := file.Open()
 			if err != nil {
-				return ReadClosers{}, err
+				return nil, nil, err
 			}
-			gafRCs.BootRC = reader
 
-		case Root:
-			reader, err := file.Open()
+			checksums, err = parseChecksums(rc)
+			rc.Close()
 			if err != nil {
-				return ReadClosers{}, err
+				return nil, nil, err
 			}
-			gafRCs.RootRC = reader
+		}
+	}
 
-		case SBOM:
-			reader, err := file.Open()
-			if err != nil {
-				return ReadClosers{}, err
-			}
-			gafRCs.SBOMRC = reader
+	// Check the gaf archive contains the files described in the gaf spec.
+	for _, name := range checksummedMembers {
+		if files[name] == nil {
+			return nil, nil, ErrMaformedGaf
 		}
 	}
 
-	if gafRCs.MBRRC == nil ||
-		gafRCs.BootRC == nil ||
-		gafRCs.RootRC == nil ||
-		gafRCs.SBOMRC == nil {
-		return ReadClosers{}, ErrMaformedGaf
+	return files, checksums, nil
+}
+
+// openMember opens the member with the name, verifying it against the checksums if any.
+func openMember(files map[string]*zip.File, checksums map[string]string, name string) (io.ReadCloser, error) {
+	rc, err := files[name].Open()
+	if err != nil {
+		return nil, err
 	}
 
-	// Unzip archive to readers.
-	return gafRCs, nil
+	if checksums != nil {
+		return newVerifyingReadCloser(rc, name, checksums[name]), nil
+	}
+
+	return rc, nil
+}
+
+// close closes the opened ReadClosers.
+func (rcs ReadClosers) close() {
+	for _, rc := range []io.ReadCloser{rcs.MBRRC, rcs.BootRC, rcs.RootRC, rcs.SBOMRC} {
+		if rc != nil {
+			rc.Close()
+		}
+	}
+}
+
+// Create writes a gaf archive with the content read from the Readers,
+// along with the checksums manifest of the content.
+func Create(ctx context.Context, dest io.Writer, readers Readers) error {
+	if readers.MBR == nil ||
+		readers.Boot == nil ||
+		readers.Root == nil ||
+		readers.SBOM == nil {
+		return ErrMissingContent
+	}
+
+	writer := zip.NewWriter(dest)
+	checksums := make(map[string]string)
+
+	for _, member := range []struct {
+		name   string
+		reader io.Reader
+	}{
+		{MBR, readers.MBR},
+		{Boot, readers.Boot},
+		{Root, readers.Root},
+		{SBOM, readers.SBOM},
+	} {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		w, err := writer.CreateHeader(&zip.FileHeader{
+			Name:     member.name,
+			Method:   zip.Deflate,
+			Modified: time.Now(),
+		})
+		if err != nil {
+			return fmt.Errorf("error creating gaf member %s: %w", member.name, err)
+		}
+
+		h := sha256.New()
+		if _, err := io.Copy(io.MultiWriter(w, h), member.reader); err != nil {
+			return fmt.Errorf("error writing gaf member %s: %w", member.name, err)
+		}
+
+		checksums[member.name] = hex.EncodeToString(h.Sum(nil))
+	}
+
+	w, err := writer.CreateHeader(&zip.FileHeader{
+		Name:     Checksums,
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("error creating gaf member %s: %w", Checksums, err)
+	}
+
+	if _, err := w.Write(formatChecksums(checksums)); err != nil {
+		return fmt.Errorf("error writing gaf member %s: %w", Checksums, err)
+	}
+
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("error finalizing gaf: %w", err)
+	}
+
+	return nil
 }
@@ -0,0 +1,107 @@
+package gaf
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+var testContent = map[string]string{MBR: "mbr", Boot: "boot", Root: "root", SBOM: "{}"}
+
+func testGaf(t *testing.T) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := Create(context.Background(), &buf, Readers{
+		MBR:  strings.NewReader(testContent[MBR]),
+		Boot: strings.NewReader(testContent[Boot]),
+		Root: strings.NewReader(testContent[Root]),
+		SBOM: strings.NewReader(testContent[SBOM]),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+// testZip returns a zip archive with the members, stored uncompressed.
+func testZip(t *testing.T, members map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range members {
+		f, err := w.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := f.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestExtractMember(t *testing.T) {
+	b := testGaf(t)
+
+	for name, want := range testContent {
+		t.Run(name, func(t *testing.T) {
+			rc, err := ExtractMember(context.Background(), bytes.NewReader(b), int64(len(b)), name)
+			if err != nil {
+				t.Fatalf("ExtractMember() = %v, want nil", err)
+			}
+			defer rc.Close()
+
+			got, err := io.ReadAll(rc)
+			if err != nil || string(got) != want {
+				t.Errorf("ExtractMember() content = %q, %v, want %q, nil", got, err, want)
+			}
+		})
+	}
+
+	if _, err := ExtractMember(context.Background(), bytes.NewReader(b), int64(len(b)), Checksums); err == nil {
+		t.Errorf("ExtractMember(%q) = nil, want an error", Checksums)
+	}
+}
+
+func TestExtractMemberInvalid(t *testing.T) {
+	missing := map[string]string{MBR: "mbr", Boot: "boot", Root: "root"}
+
+	corrupt := map[string]string{Checksums: string(formatChecksums(map[string]string{
+		MBR:  strings.Repeat("0", 64),
+		Boot: strings.Repeat("0", 64),
+		Root: strings.Repeat("0", 64),
+		SBOM: strings.Repeat("0", 64),
+	}))}
+	for name, content := range testContent {
+		corrupt[name] = content
+	}
+
+	b := testZip(t, missing)
+	if _, err := ExtractMember(context.Background(), bytes.NewReader(b), int64(len(b)), MBR); !errors.Is(err, ErrMaformedGaf) {
+		t.Errorf("ExtractMember() of a gaf without %s = %v, want %v", SBOM, err, ErrMaformedGaf)
+	}
+
+	b = testZip(t, corrupt)
+	rc, err := ExtractMember(context.Background(), bytes.NewReader(b), int64(len(b)), Boot)
+	if err != nil {
+		t.Fatalf("ExtractMember() = %v, want nil", err)
+	}
+	defer rc.Close()
+
+	var checksumErr *ChecksumError
+	if _, err := io.ReadAll(rc); !errors.As(err, &checksumErr) || checksumErr.Member != Boot {
+		t.Errorf("reading a corrupt member = %v, want a ChecksumError for %s", err, Boot)
+	}
+}
@@ -0,0 +1,177 @@
+package gaf
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/CalebQ42/squashfs"
+	"github.com/damdo/gokrazy-machine/internal/disk"
+)
+
+const (
+	builddirPrefix = "builddir/"
+	goModSuffix    = "/go.mod"
+	userDir        = "user"
+)
+
+// ErrSBOMMismatch denotes the error for a failed verification of the sbom
+// against the content it describes.
+var ErrSBOMMismatch = errors.New("sbom does not match")
+
+// FileHash is the hash of a file that went into building a gokrazy image.
+type FileHash struct {
+	// Path is the path of the file, relative to the gokrazy instance directory.
+	Path string `json:"path"`
+
+	// Hash is the hex encoded SHA256 of the file content.
+	Hash string `json:"hash"`
+}
+
+// BOM is the software bill of materials of a gokrazy image,
+// as generated by gok.
+type BOM struct {
+	ConfigHash      FileHash   `json:"config_hash"`
+	GoModHashes     []FileHash `json:"go_mod_hashes"`
+	ExtraFileHashes []FileHash `json:"extra_file_hashes"`
+}
+
+// SBOMWithHash is the content of the gaf sbom.json.
+type SBOMWithHash struct {
+	// SBOMHash is the hex encoded SHA256 of the indented JSON encoding of SBOM.
+	SBOMHash string `json:"sbom_hash"`
+
+	SBOM BOM `json:"sbom"`
+}
+
+// ParseSBOM decodes the content of a sbom.json.
+func ParseSBOM(r io.Reader) (SBOMWithHash, error) {
+	var s SBOMWithHash
+	if err := json.NewDecoder(r).Decode(&s); err != nil {
+		return SBOMWithHash{}, fmt.Errorf("error decoding sbom: %w", err)
+	}
+
+	return s, nil
+}
+
+// Packages returns the packages the image was built with,
+// derived from the build directories of their go.mod files.
+func (s SBOMWithHash) Packages() []string {
+	var pkgs []string
+	for _, h := range s.SBOM.GoModHashes {
+		if !strings.HasPrefix(h.Path, builddirPrefix) || !strings.HasSuffix(h.Path, goModSuffix) {
+			continue
+		}
+
+		pkgs = append(pkgs, strings.TrimSuffix(strings.TrimPrefix(h.Path, builddirPrefix), goModSuffix))
+	}
+
+	return pkgs
+}
+
+// Verify checks the declared sbom hash matches the sbom content.
+func (s SBOMWithHash) Verify() error {
+	b, err := json.MarshalIndent(s.SBOM, "", "    ")
+	if err != nil {
+		return fmt.Errorf("error encoding sbom: %w", err)
+	}
+
+	sum := sha256.Sum256(b)
+	if got := hex.EncodeToString(sum[:]); got != s.SBOMHash {
+		return fmt.Errorf("%w: declared sbom hash %s, computed %s", ErrSBOMMismatch, s.SBOMHash, got)
+	}
+
+	return nil
+}
+
+// VerifyRoot checks the sbom describes the content of the root squashfs:
+// the sbom embedded in the root, if any, must have the same hash.
+// The binaries in /user not named after a package of the sbom are only logged:
+// the sbom lists the build directories of gok, which are often module level
+// and as such don't name the binaries built from the packages of the module.
+func (s SBOMWithHash) VerifyRoot(root io.ReaderAt) error {
+	if err := s.Verify(); err != nil {
+		return err
+	}
+
+	rd, err := squashfs.NewReader(root)
+	if err != nil {
+		return fmt.Errorf("error reading root squashFS: %w", err)
+	}
+
+	b, err := rd.ReadFile(disk.SBOMPath)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		// Older gokrazy images don't embed the sbom.
+
+	case err != nil:
+		return fmt.Errorf("error reading root squashFS SBOM file %q: %w", disk.SBOMPath, err)
+
+	default:
+		var rootSBOM SBOMWithHash
+		if err := json.Unmarshal(b, &rootSBOM); err != nil {
+			return fmt.Errorf("error decoding root squashFS SBOM file %q: %w", disk.SBOMPath, err)
+		}
+
+		if rootSBOM.SBOMHash != s.SBOMHash {
+			return fmt.Errorf("%w: sbom hash %s, root sbom hash %s", ErrSBOMMismatch, s.SBOMHash, rootSBOM.SBOMHash)
+		}
+	}
+
+	entries, err := rd.ReadDir(userDir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("error listing root squashFS directory %q: %w", userDir, err)
+	}
+
+	binaries := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if !e.IsDir() {
+			binaries = append(binaries, e.Name())
+		}
+	}
+
+	for _, b := range s.unlistedBinaries(binaries) {
+		log.Printf("warning: binary /%s/%s is not named after any sbom package\n", userDir, b)
+	}
+
+	return nil
+}
+
+// unlistedBinaries returns the binaries not named after a package of the sbom.
+func (s SBOMWithHash) unlistedBinaries(binaries []string) []string {
+	names := make(map[string]bool)
+	for _, pkg := range s.Packages() {
+		names[binaryName(pkg)] = true
+	}
+
+	var unlisted []string
+	for _, b := range binaries {
+		if !names[b] {
+			unlisted = append(unlisted, b)
+		}
+	}
+
+	return unlisted
+}
+
+// majorVersionElement matches the major version element of a module path, e.g. v2 (but not v0 nor v1).
+var majorVersionElement = regexp.MustCompile(`^v([2-9]|[1-9][0-9]+)$`)
+
+// binaryName returns the name of the binary go build names after the package path:
+// its last element, or the one before if the last is a major version (example.com/foo/v2 builds foo).
+func binaryName(pkg string) string {
+	dir, base := path.Split(path.Clean(pkg))
+	if majorVersionElement.MatchString(base) && dir != "" {
+		return path.Base(dir)
+	}
+
+	return base
+}
@@ -0,0 +1,76 @@
+package gaf
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func testSBOM(t *testing.T, goModPaths ...string) SBOMWithHash {
+	t.Helper()
+
+	var s SBOMWithHash
+	s.SBOM.ConfigHash = FileHash{Path: "config.json", Hash: "00"}
+	for _, p := range goModPaths {
+		s.SBOM.GoModHashes = append(s.SBOM.GoModHashes, FileHash{Path: p, Hash: "00"})
+	}
+
+	b, err := json.MarshalIndent(s.SBOM, "", "    ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha256.Sum256(b)
+	s.SBOMHash = hex.EncodeToString(sum[:])
+
+	return s
+}
+
+func TestVerify(t *testing.T) {
+	s := testSBOM(t, "builddir/github.com/gokrazy/hello/go.mod")
+	if err := s.Verify(); err != nil {
+		t.Fatalf("Verify() = %v, want nil", err)
+	}
+
+	s.SBOM.ExtraFileHashes = append(s.SBOM.ExtraFileHashes, FileHash{Path: "extrafiles/etc/motd", Hash: "00"})
+	if err := s.Verify(); !errors.Is(err, ErrSBOMMismatch) {
+		t.Errorf("Verify() of a modified sbom = %v, want %v", err, ErrSBOMMismatch)
+	}
+}
+
+func TestUnlistedBinaries(t *testing.T) {
+	s := testSBOM(t,
+		"builddir/github.com/gokrazy/hello/go.mod",
+		"builddir/github.com/gokrazy/breakglass/v2/go.mod",
+		"builddir/github.com/gokrazy/serial-busybox/go.mod",
+		"builddir/github.com/gokrazy/gokrazy/go.mod",
+		"go.mod",
+	)
+
+	tests := []struct {
+		name     string
+		binaries []string
+		want     []string
+	}{
+		{name: "all listed", binaries: []string{"hello", "breakglass", "serial-busybox"}},
+		{name: "major version suffix", binaries: []string{"breakglass"}},
+		{name: "none", binaries: nil},
+		{
+			name:     "module level builddir",
+			binaries: []string{"hello", "dhcp", "ntp"},
+			want:     []string{"dhcp", "ntp"},
+		},
+		{name: "not the version element", binaries: []string{"v2"}, want: []string{"v2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.unlistedBinaries(tt.binaries); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unlistedBinaries(%q) = %q, want %q", tt.binaries, got, tt.want)
+			}
+		})
+	}
+}
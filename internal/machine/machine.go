@@ -0,0 +1,120 @@
+package machine
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"syscall"
+)
+
+const (
+	// envRuntimeDir is the environment variable of the user runtime directory.
+	envRuntimeDir = "XDG_RUNTIME_DIR"
+
+	// QMPSocketFile is the name of the QMP socket file in the directory of a machine.
+	QMPSocketFile = "qmp.sock"
+)
+
+// maxNameLength is the maximum length of machine names.
+// The paths of their sockets must also fit the unix socket path limit, see Create.
+const maxNameLength = 48
+
+// maxSocketPathLength is the longest path of a unix socket on the platform, without the trailing NUL.
+var maxSocketPathLength = len(syscall.RawSockaddrUnix{}.Path) - 1
+
+var dirPermission fs.FileMode = 0700
+
+var namePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+var (
+	// ErrInvalidName denotes the error for a machine name that can't be used.
+	ErrInvalidName = errors.New("invalid machine name")
+
+	// ErrNameInUse denotes the error for the name of a running machine.
+	ErrNameInUse = errors.New("machine name already in use")
+
+	// ErrSocketPathTooLong denotes the error for a machine whose socket path exceeds the unix socket path limit.
+	ErrSocketPathTooLong = errors.New("machine socket path too long")
+)
+
+// RuntimeDir returns the directory holding the directories of the running machines,
+// gom in the user runtime directory if any, or in the temporary directory otherwise.
+func RuntimeDir() string {
+	if dir := os.Getenv(envRuntimeDir); dir != "" {
+		return filepath.Join(dir, "gom")
+	}
+
+	return filepath.Join(os.TempDir(), "gom-"+strconv.Itoa(os.Getuid()))
+}
+
+// Dir returns the directory of the machine with the name, holding its control sockets.
+func Dir(name string) string {
+	return filepath.Join(RuntimeDir(), name)
+}
+
+// QMPSocket returns the path of the QMP socket of the machine with the name.
+func QMPSocket(name string) string {
+	return filepath.Join(Dir(name), QMPSocketFile)
+}
+
+// Create creates the directory of the machine with the name, reserving the name.
+// It fails with ErrNameInUse if a running machine has the name,
+// the directory of a dead machine with the name is reused.
+// It fails with ErrSocketPathTooLong if the path of the QMP socket of the machine
+// exceeds the unix socket path limit of the platform (e.g. with a long temporary directory on macOS).
+func Create(name string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+
+	if p := QMPSocket(name); len(p) > maxSocketPathLength {
+		return fmt.Errorf("%w: %s is %d bytes, above the limit of %d, use a shorter name or set %s to a shorter directory",
+			ErrSocketPathTooLong, p, len(p), maxSocketPathLength, envRuntimeDir)
+	}
+
+	if err := os.MkdirAll(RuntimeDir(), dirPermission); err != nil {
+		return fmt.Errorf("error creating machine directory: %w", err)
+	}
+
+	err := os.Mkdir(Dir(name), dirPermission)
+	if errors.Is(err, fs.ErrExist) {
+		// Lookup removes the directory if the machine is dead.
+		if _, err := Lookup(name); !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("%w: %s", ErrNameInUse, name)
+		}
+
+		err = os.Mkdir(Dir(name), dirPermission)
+		if errors.Is(err, fs.ErrExist) {
+			return fmt.Errorf("%w: %s", ErrNameInUse, name)
+		}
+	}
+
+	if err != nil {
+		return fmt.Errorf("error creating machine directory: %w", err)
+	}
+
+	return nil
+}
+
+// ValidateName checks the name can be used for a machine.
+func ValidateName(name string) error {
+	if len(name) > maxNameLength || !namePattern.MatchString(name) {
+		return fmt.Errorf("%w: %q, expected up to %d letters, digits, '.', '_' or '-', starting with a letter or digit",
+			ErrInvalidName, name, maxNameLength)
+	}
+
+	return nil
+}
+
+// Remove removes the directory of the machine with the name.
+func Remove(name string) error {
+	if err := os.RemoveAll(Dir(name)); err != nil {
+		return fmt.Errorf("error removing machine directory: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package machine
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCreate(t *testing.T) {
+	t.Setenv(envRuntimeDir, t.TempDir())
+
+	const name = "gokrazy"
+
+	if err := Create(name); err != nil {
+		t.Fatalf("Create(%q) = %v, want nil", name, err)
+	}
+
+	// The name is reserved while its machine is being set up...
+	if err := Create(name); !errors.Is(err, ErrNameInUse) {
+		t.Errorf("Create(%q) of a machine being set up = %v, want %v", name, err, ErrNameInUse)
+	}
+
+	// ...and while it runs.
+	if err := WriteState(State{Name: name, PID: os.Getpid(), Started: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Create(name); !errors.Is(err, ErrNameInUse) {
+		t.Errorf("Create(%q) of a running machine = %v, want %v", name, err, ErrNameInUse)
+	}
+
+	// The name of a dead machine is reused.
+	if err := WriteState(State{Name: name, PID: deadPID(t), Started: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Create(name); err != nil {
+		t.Errorf("Create(%q) of a dead machine = %v, want nil", name, err)
+	}
+
+	if _, err := os.Stat(filepath.Join(Dir(name), StateFile)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("state of the dead machine after Create: %v, want removed", err)
+	}
+
+	if err := Remove(name); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Create(name); err != nil {
+		t.Errorf("Create(%q) after Remove = %v, want nil", name, err)
+	}
+}
+
+func TestCreateInvalid(t *testing.T) {
+	tests := []struct {
+		name       string
+		runtimeDir string
+		want       error
+	}{
+		{name: "", want: ErrInvalidName},
+		{name: "-gokrazy", want: ErrInvalidName},
+		{name: "../gokrazy", want: ErrInvalidName},
+		{name: "gokrazy machine", want: ErrInvalidName},
+		{name: strings.Repeat("a", maxNameLength+1), want: ErrInvalidName},
+		{name: "gokrazy", runtimeDir: strings.Repeat("d", maxSocketPathLength), want: ErrSocketPathTooLong},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			t.Setenv(envRuntimeDir, filepath.Join(dir, tt.runtimeDir))
+
+			if err := Create(tt.name); !errors.Is(err, tt.want) {
+				t.Errorf("Create(%q) = %v, want %v", tt.name, err, tt.want)
+			}
+
+			if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+				t.Errorf("Create(%q) left %d entries in the runtime directory", tt.name, len(entries))
+			}
+		})
+	}
+}
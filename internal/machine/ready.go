@@ -0,0 +1,187 @@
+package machine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+const (
+	// DefaultReadyPath is the HTTP path probed by default, the gokrazy web UI.
+	DefaultReadyPath = "/"
+
+	// DefaultReadyInterval is the default interval between two probes.
+	DefaultReadyInterval = time.Second
+)
+
+// readyRequestTimeout is the timeout of each HTTP probe.
+const readyRequestTimeout = 2 * time.Second
+
+var (
+	// ErrNotReady denotes the error for a machine that didn't become ready in time.
+	ErrNotReady = errors.New("machine not ready")
+
+	// ErrNoReadyProbe denotes the error for a machine without a forwarded guest port 80 nor a serial marker to probe.
+	ErrNoReadyProbe = errors.New("no readiness probe for the machine, it has no forwarded port 80 and no serial marker was given")
+
+	// ErrExited denotes the error for a machine whose qemu exited.
+	ErrExited = errors.New("machine exited")
+)
+
+// ReadyOptions are the options of WaitReady.
+type ReadyOptions struct {
+	// Path is the HTTP path probed on the host port forwarded to the guest port 80,
+	// DefaultReadyPath if empty.
+	Path string
+
+	// SerialMarker, if not empty, is the text to wait for in the serial console log.
+	SerialMarker string
+
+	// Interval is the interval between two probes, DefaultReadyInterval if zero.
+	Interval time.Duration
+}
+
+// WaitReady waits until the running machine with the name is ready, or the context is done.
+// The machine is ready when the HTTP server forwarded from the guest port 80 answers Path
+// with a success or an authentication challenge (the gokrazy web UI asks for its password),
+// and when SerialMarker, if any, is in its serial console log.
+// The HTTP probe is skipped if the guest port 80 is not forwarded (e.g. with a shared network).
+func WaitReady(ctx context.Context, name string, opts ReadyOptions) error {
+	if opts.Path == "" {
+		opts.Path = DefaultReadyPath
+	}
+
+	if opts.Interval == 0 {
+		opts.Interval = DefaultReadyInterval
+	}
+
+	client := &http.Client{Timeout: readyRequestTimeout}
+	serial := serialMarker{marker: []byte(opts.SerialMarker)}
+
+	httpReady, serialReady := false, opts.SerialMarker == ""
+
+	var lastErr error
+
+	for {
+		s, err := Lookup(name)
+		if err != nil {
+			return err
+		}
+
+		if !s.Starting() {
+			if !processAlive(s.QemuPID) {
+				return fmt.Errorf("%w: %s", ErrExited, name)
+			}
+
+			p, forwarded := s.Port(GuestPortHTTP)
+			if !forwarded && opts.SerialMarker == "" {
+				return fmt.Errorf("%w: %s", ErrNoReadyProbe, name)
+			}
+
+			if !httpReady {
+				if forwarded {
+					lastErr = probeHTTP(ctx, client, fmt.Sprintf("http://localhost:%d%s", p.Host, opts.Path))
+					httpReady = lastErr == nil
+				} else {
+					httpReady = true
+				}
+			}
+
+			if !serialReady {
+				serialReady, err = serial.find(s.SerialLog)
+				if err != nil {
+					return err
+				}
+
+				if !serialReady && httpReady {
+					lastErr = fmt.Errorf("%q not found in the serial console log", opts.SerialMarker) //nolint:goerr113
+				}
+			}
+
+			if httpReady && serialReady {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			if lastErr != nil {
+				return fmt.Errorf("%w: %s: %w", ErrNotReady, name, lastErr)
+			}
+
+			return fmt.Errorf("%w: %s: %w", ErrNotReady, name, ctx.Err())
+		case <-time.After(opts.Interval):
+		}
+	}
+}
+
+// probeHTTP returns nil if the HTTP server at url answers with a success or an authentication challenge.
+func probeHTTP(ctx context.Context, client *http.Client, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("error creating request for %s: %w", url, err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode < http.StatusBadRequest,
+		resp.StatusCode == http.StatusUnauthorized,
+		resp.StatusCode == http.StatusForbidden:
+		return nil
+	default:
+		return fmt.Errorf("GET %s: %s", url, resp.Status) //nolint:goerr113
+	}
+}
+
+// serialMarker looks for a marker in a serial console log as it grows.
+type serialMarker struct {
+	marker []byte
+	offset int64
+	tail   []byte
+}
+
+// find reports whether the marker is in the log at path, reading only what was appended since the last call.
+func (m *serialMarker) find(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, fmt.Errorf("error opening serial console log: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(m.offset, io.SeekStart); err != nil {
+		return false, fmt.Errorf("error reading serial console log: %w", err)
+	}
+
+	b, err := io.ReadAll(f)
+	if err != nil {
+		return false, fmt.Errorf("error reading serial console log: %w", err)
+	}
+
+	m.offset += int64(len(b))
+
+	// Keep the end of what was read, for the markers split across two reads.
+	b = append(m.tail, b...)
+	if bytes.Contains(b, m.marker) {
+		return true, nil
+	}
+
+	if keep := len(m.marker) - 1; len(b) > keep {
+		b = b[len(b)-keep:]
+	}
+
+	m.tail = b
+
+	return false, nil
+}
@@ -0,0 +1,174 @@
+package machine
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testMachine records the state of a running machine forwarding the guest port 80 to the server, if any.
+func testMachine(t *testing.T, srv *httptest.Server) State {
+	t.Helper()
+
+	t.Setenv(envRuntimeDir, t.TempDir())
+
+	s := State{Name: "ready", PID: os.Getpid(), QemuPID: os.Getpid(), Started: time.Now()}
+	if err := Create(s.Name); err != nil {
+		t.Fatal(err)
+	}
+
+	s.SerialLog = SerialLog(s.Name)
+	if err := os.WriteFile(s.SerialLog, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if srv != nil {
+		_, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		p, err := strconv.Atoi(port)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s.Ports = []Port{{Host: p, Guest: GuestPortHTTP}}
+	}
+
+	if err := WriteState(s); err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+func TestWaitReady(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  []int // the status of the successive answers, the last one repeated
+		path    string
+		want    error
+		wantHit string
+	}{
+		{name: "ok", status: []int{http.StatusOK}},
+		{name: "authentication challenge", status: []int{http.StatusUnauthorized}},
+		{name: "forbidden", status: []int{http.StatusForbidden}},
+		{name: "after a few probes", status: []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusOK}},
+		{name: "custom path", status: []int{http.StatusOK}, path: "/healthz", wantHit: "/healthz"},
+		{name: "never ready", status: []int{http.StatusServiceUnavailable}, want: ErrNotReady},
+		{name: "not found", status: []int{http.StatusNotFound}, want: ErrNotReady},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var probes atomic.Int32
+			var hit atomic.Value
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				hit.Store(r.URL.Path)
+
+				i := int(probes.Add(1)) - 1
+				if i >= len(tt.status) {
+					i = len(tt.status) - 1
+				}
+
+				w.WriteHeader(tt.status[i])
+			}))
+			defer srv.Close()
+
+			s := testMachine(t, srv)
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			err := WaitReady(ctx, s.Name, ReadyOptions{Path: tt.path, Interval: 10 * time.Millisecond})
+			if !errors.Is(err, tt.want) || (tt.want == nil && err != nil) {
+				t.Fatalf("WaitReady() = %v, want %v", err, tt.want)
+			}
+
+			wantHit := tt.wantHit
+			if wantHit == "" {
+				wantHit = DefaultReadyPath
+			}
+
+			if got, _ := hit.Load().(string); got != wantHit {
+				t.Errorf("WaitReady() probed %q, want %q", got, wantHit)
+			}
+		})
+	}
+}
+
+func TestWaitReadySerial(t *testing.T) {
+	const marker = "gokrazy: ready"
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	for _, withHTTP := range []bool{true, false} {
+		t.Run("http="+strconv.FormatBool(withHTTP), func(t *testing.T) {
+			var s State
+			if withHTTP {
+				s = testMachine(t, srv)
+			} else {
+				s = testMachine(t, nil)
+			}
+
+			f, err := os.OpenFile(s.SerialLog, os.O_APPEND|os.O_WRONLY, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			// Write the marker across two writes, seen in two reads.
+			go func() {
+				_, _ = f.WriteString("booting...\ngokrazy: re")
+				time.Sleep(50 * time.Millisecond)
+				_, _ = f.WriteString("ady\n")
+			}()
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			opts := ReadyOptions{SerialMarker: marker, Interval: 10 * time.Millisecond}
+			if err := WaitReady(ctx, s.Name, opts); err != nil {
+				t.Fatalf("WaitReady() = %v, want nil", err)
+			}
+		})
+	}
+}
+
+func TestWaitReadyErrors(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	opts := ReadyOptions{Interval: 10 * time.Millisecond}
+
+	s := testMachine(t, nil)
+	if err := WaitReady(ctx, s.Name, opts); !errors.Is(err, ErrNoReadyProbe) {
+		t.Errorf("WaitReady() without probe = %v, want %v", err, ErrNoReadyProbe)
+	}
+
+	s.QemuPID = deadPID(t)
+	if err := WriteState(s); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WaitReady(ctx, s.Name, opts); !errors.Is(err, ErrExited) {
+		t.Errorf("WaitReady() of an exited machine = %v, want %v", err, ErrExited)
+	}
+
+	if err := Remove(s.Name); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WaitReady(ctx, s.Name, opts); !errors.Is(err, ErrNotFound) {
+		t.Errorf("WaitReady() of a removed machine = %v, want %v", err, ErrNotFound)
+	}
+}
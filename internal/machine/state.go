@@ -0,0 +1,292 @@
+package machine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+)
+
+const (
+	// StateFile is the name of the state file in the directory of a machine.
+	StateFile = "state.json"
+
+	// SerialLogFile is the name of the log of the serial console in the directory of a machine.
+	SerialLogFile = "serial.log"
+
+	// LogFile is the name of the log of gom in the directory of a detached machine.
+	LogFile = "gom.log"
+)
+
+var stateFilePermission fs.FileMode = 0600
+
+// ErrNotFound denotes the error for a name without a running machine.
+var ErrNotFound = errors.New("no such machine")
+
+// The guest ports of the gokrazy web UI and of ssh (breakglass).
+const (
+	GuestPortHTTP  = 80
+	GuestPortHTTPS = 443
+	GuestPortSSH   = 22
+)
+
+// Port is a TCP port of the host forwarded to the machine.
+type Port struct {
+	Host  int `json:"host"`
+	Guest int `json:"guest"`
+}
+
+func (p Port) String() string {
+	return fmt.Sprintf("%d->%d/tcp", p.Host, p.Guest)
+}
+
+// State is the state of a machine, recorded in its directory while it runs.
+type State struct {
+	// Name is the name of the machine.
+	Name string `json:"name"`
+
+	// PID is the pid of the gom process running the machine.
+	PID int `json:"pid"`
+
+	// QemuPID is the pid of the qemu process of the machine, zero until it is started.
+	QemuPID int `json:"qemu_pid,omitempty"`
+
+	// Detached reports whether the machine runs in the background.
+	Detached bool `json:"detached"`
+
+	// Started is when the machine was started.
+	Started time.Time `json:"started"`
+
+	// Args are the qemu command line.
+	Args []string `json:"args,omitempty"`
+
+	// Ports are the ports of the host forwarded to the machine.
+	Ports []Port `json:"ports,omitempty"`
+
+	// Disks are the paths of the disk images of the machine.
+	Disks []string `json:"disks,omitempty"`
+
+	// SerialLog is the path of the log of the serial console of the machine.
+	SerialLog string `json:"serial_log,omitempty"`
+
+	// Ready reports whether the machine passed its readiness probe, when started with one.
+	Ready bool `json:"ready,omitempty"`
+}
+
+// Info is the summary of a running machine, with its resolved port mappings.
+type Info struct {
+	Name string `json:"name"`
+
+	// PID is the pid of the qemu process of the machine.
+	PID int `json:"pid"`
+
+	// WebURL is the URL of the gokrazy web UI, if the guest port 80 is forwarded.
+	WebURL string `json:"web_url,omitempty"`
+
+	// SSHPort and HTTPSPort are the ports of the host forwarded to the guest ports 22 and 443, if any.
+	SSHPort   int `json:"ssh_port,omitempty"`
+	HTTPSPort int `json:"https_port,omitempty"`
+
+	// SerialLog is the path of the log of the serial console.
+	SerialLog string `json:"serial_log"`
+
+	// Ports are all the ports of the host forwarded to the machine.
+	Ports []Port `json:"ports"`
+}
+
+// Info returns the summary of the machine.
+func (s State) Info() Info {
+	info := Info{Name: s.Name, PID: s.QemuPID, SerialLog: s.SerialLog, Ports: s.Ports}
+
+	if info.Ports == nil {
+		info.Ports = []Port{}
+	}
+
+	if p, ok := s.Port(GuestPortHTTP); ok {
+		info.WebURL = fmt.Sprintf("http://localhost:%d", p.Host)
+	}
+
+	if p, ok := s.Port(GuestPortSSH); ok {
+		info.SSHPort = p.Host
+	}
+
+	if p, ok := s.Port(GuestPortHTTPS); ok {
+		info.HTTPSPort = p.Host
+	}
+
+	return info
+}
+
+// Alive reports whether the gom or the qemu process of the machine is still running.
+func (s State) Alive() bool {
+	return processAlive(s.PID) || processAlive(s.QemuPID)
+}
+
+// Starting reports whether the machine is being set up and qemu is not started yet.
+func (s State) Starting() bool {
+	return s.QemuPID == 0
+}
+
+// processAlive reports whether the process with the pid exists,
+// including processes of other users (e.g. qemu run with sudo).
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+
+	err := syscall.Kill(pid, 0)
+
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// SerialLog returns the path of the log of the serial console of the machine with the name.
+func SerialLog(name string) string {
+	return filepath.Join(Dir(name), SerialLogFile)
+}
+
+// Log returns the path of the log of gom for the detached machine with the name.
+func Log(name string) string {
+	return filepath.Join(Dir(name), LogFile)
+}
+
+// WriteState records the state of the machine in its directory.
+func WriteState(s State) error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to json encode machine state: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(Dir(s.Name), ".state-")
+	if err != nil {
+		return fmt.Errorf("error writing machine state: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if _, err := tmp.Write(b); err != nil {
+		return fmt.Errorf("error writing machine state: %w", err)
+	}
+
+	if err := tmp.Chmod(stateFilePermission); err != nil {
+		return fmt.Errorf("error writing machine state: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing machine state: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), filepath.Join(Dir(s.Name), StateFile)); err != nil {
+		return fmt.Errorf("error writing machine state: %w", err)
+	}
+
+	return nil
+}
+
+// ReadState returns the state of the machine with the name.
+func ReadState(name string) (State, error) {
+	if err := ValidateName(name); err != nil {
+		return State{}, err
+	}
+
+	b, err := os.ReadFile(filepath.Join(Dir(name), StateFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return State{}, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+
+	if err != nil {
+		return State{}, fmt.Errorf("error reading machine state: %w", err)
+	}
+
+	var s State
+	if err := json.Unmarshal(b, &s); err != nil {
+		return State{}, fmt.Errorf("error decoding machine state of %s: %w", name, err)
+	}
+
+	return s, nil
+}
+
+// Port returns the port of the host forwarded to the guest port of the machine.
+func (s State) Port(guest int) (Port, bool) {
+	for _, p := range s.Ports {
+		if p.Guest == guest {
+			return p, true
+		}
+	}
+
+	return Port{}, false
+}
+
+// Lookup returns the state of the running machine with the name,
+// removing its directory if the machine died without cleaning it up.
+func Lookup(name string) (State, error) {
+	s, err := ReadState(name)
+	if err != nil {
+		return State{}, err
+	}
+
+	if !s.Alive() {
+		if err := Remove(name); err != nil {
+			return State{}, err
+		}
+
+		return State{}, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+
+	return s, nil
+}
+
+// List returns the states of the running machines, oldest first,
+// removing the directories of the machines that died without cleaning them up.
+func List() ([]State, error) {
+	entries, err := os.ReadDir(RuntimeDir())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error listing machines: %w", err)
+	}
+
+	var states []State
+	for _, e := range entries {
+		if !e.IsDir() || ValidateName(e.Name()) != nil {
+			continue
+		}
+
+		s, err := Lookup(e.Name())
+		switch {
+		case errors.Is(err, ErrNotFound):
+			cleanupOrphanDir(e.Name())
+		case err != nil:
+			return nil, err
+		default:
+			states = append(states, s)
+		}
+	}
+
+	sort.Slice(states, func(i, j int) bool { return states[i].Started.Before(states[j].Started) })
+
+	return states, nil
+}
+
+// orphanDirTTL is how long a machine directory without a state file is kept,
+// leaving time to the gom process creating it to write the state.
+const orphanDirTTL = time.Minute
+
+// cleanupOrphanDir removes the directory of the machine with the name
+// if it has no state file since a while.
+func cleanupOrphanDir(name string) {
+	fi, err := os.Stat(Dir(name))
+	if err != nil || time.Since(fi.ModTime()) < orphanDirTTL {
+		return
+	}
+
+	if _, err := os.Stat(filepath.Join(Dir(name), StateFile)); errors.Is(err, fs.ErrNotExist) {
+		_ = Remove(name)
+	}
+}
@@ -0,0 +1,99 @@
+package machine
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+// deadPID returns the pid of a process that exited.
+func deadPID(t *testing.T) int {
+	t.Helper()
+
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	return cmd.Process.Pid
+}
+
+func TestLookup(t *testing.T) {
+	t.Setenv(envRuntimeDir, t.TempDir())
+
+	alive := State{Name: "alive", PID: os.Getpid(), Started: time.Now()}
+	stale := State{Name: "stale", PID: deadPID(t), QemuPID: deadPID(t), Started: time.Now()}
+
+	for _, s := range []State{alive, stale} {
+		if err := Create(s.Name); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := WriteState(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := Lookup(alive.Name)
+	if err != nil || got.PID != alive.PID {
+		t.Errorf("Lookup(%q) = %+v, %v, want the machine state", alive.Name, got, err)
+	}
+
+	if _, err := Lookup(stale.Name); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Lookup(%q) = %v, want %v", stale.Name, err, ErrNotFound)
+	}
+
+	if _, err := os.Stat(Dir(stale.Name)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("directory of the stale machine after Lookup: %v, want removed", err)
+	}
+
+	if _, err := Lookup("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Lookup(%q) = %v, want %v", "missing", err, ErrNotFound)
+	}
+}
+
+func TestList(t *testing.T) {
+	t.Setenv(envRuntimeDir, t.TempDir())
+
+	now := time.Now()
+	states := []State{
+		{Name: "second", PID: os.Getpid(), Started: now},
+		{Name: "stale", PID: deadPID(t), Started: now},
+		{Name: "first", PID: os.Getpid(), Started: now.Add(-time.Minute)},
+	}
+
+	for _, s := range states {
+		if err := Create(s.Name); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := WriteState(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A machine being set up, without a state yet.
+	if err := Create("starting"); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, s := range got {
+		names = append(names, s.Name)
+	}
+
+	if len(names) != 2 || names[0] != "first" || names[1] != "second" {
+		t.Errorf("List() = %q, want [first second]", names)
+	}
+
+	if _, err := os.Stat(Dir("starting")); err != nil {
+		t.Errorf("directory of the machine being set up after List: %v, want kept", err)
+	}
+}
@@ -0,0 +1,130 @@
+package oci
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// progressInterval is the minimum interval between two progress reports of a blob.
+const progressInterval = 200 * time.Millisecond
+
+// Progress is the progress of a blob download or upload.
+type Progress struct {
+	// Name is the file name of the blob.
+	Name string
+
+	// Done is the number of bytes transferred so far.
+	Done int64
+
+	// Total is the size of the blob.
+	Total int64
+
+	// Rate is the average transfer rate, in bytes per second.
+	Rate float64
+}
+
+func (p Progress) String() string {
+	return fmt.Sprintf("%s %s / %s (%s/s)",
+		p.Name, byteCountIEC(p.Done), byteCountIEC(p.Total), byteCountIEC(int64(p.Rate)))
+}
+
+// ProgressFunc is called with the progress of blob transfers,
+// and once when a blob transfer completes.
+// It can be called concurrently for the blobs transferred in parallel.
+type ProgressFunc func(Progress)
+
+// progressReader reports the progress of the reads from r.
+type progressReader struct {
+	r        io.Reader
+	progress Progress
+	fn       ProgressFunc
+	start    time.Time
+	last     time.Time
+	resumed  int64
+	finished bool
+}
+
+// newProgressReader returns a reader reporting the progress of the reads from r to fn,
+// for a blob of which done bytes were already transferred.
+func newProgressReader(r io.Reader, name string, done, total int64, fn ProgressFunc) io.Reader {
+	if fn == nil {
+		return r
+	}
+
+	now := time.Now()
+
+	return &progressReader{
+		r:        r,
+		progress: Progress{Name: name, Done: done, Total: total},
+		fn:       fn,
+		start:    now,
+		last:     now,
+		resumed:  done,
+	}
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.progress.Done += int64(n)
+
+	now := time.Now()
+	finished := p.progress.Done >= p.progress.Total
+
+	if p.finished || (now.Sub(p.last) < progressInterval && !finished) {
+		return n, err
+	}
+
+	p.finished = finished
+
+	p.last = now
+	if elapsed := now.Sub(p.start).Seconds(); elapsed > 0 {
+		p.progress.Rate = float64(p.progress.Done-p.resumed) / elapsed
+	}
+
+	p.fn(p.progress)
+
+	return n, err
+}
+
+// writeVerified streams the blob of the descriptor read from r to dest,
+// through a temporary file that is only renamed to dest if the content
+// matches the descriptor size and digest.
+func writeVerified(desc ocispec.Descriptor, r io.Reader, dest string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".tmp-")
+	if err != nil {
+		return fmt.Errorf("error creating temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	verifier := desc.Digest.Verifier()
+
+	// Read one byte more than expected to detect oversized content.
+	n, err := io.Copy(io.MultiWriter(tmp, verifier), io.LimitReader(r, desc.Size+1))
+	if err != nil {
+		return fmt.Errorf("error writing blob %s: %w", desc.Digest, err)
+	}
+
+	if n != desc.Size || !verifier.Verified() {
+		return fmt.Errorf("%w: blob %s", ErrDigestMismatch, desc.Digest)
+	}
+
+	if err := tmp.Chmod(blobFilePermission); err != nil {
+		return fmt.Errorf("error setting permissions of blob %s: %w", desc.Digest, err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error closing temporary file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return fmt.Errorf("error moving blob %s to %s: %w", desc.Digest, dest, err)
+	}
+
+	return nil
+}
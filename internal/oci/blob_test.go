@@ -0,0 +1,82 @@
+package oci
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/iotest"
+)
+
+func TestWriteVerified(t *testing.T) {
+	content := []byte("gokrazy root")
+	desc := testDescriptor(content)
+	errRead := errors.New("connection reset")
+
+	tests := []struct {
+		name    string
+		r       io.Reader
+		wantErr error
+	}{
+		{name: "match", r: bytes.NewReader(content)},
+		{name: "chunked", r: iotest.OneByteReader(bytes.NewReader(content))},
+		{name: "truncated", r: bytes.NewReader(content[:5]), wantErr: ErrDigestMismatch},
+		{name: "oversized", r: bytes.NewReader(append(content, '!')), wantErr: ErrDigestMismatch},
+		{name: "other content", r: bytes.NewReader([]byte("gokrazy boot")), wantErr: ErrDigestMismatch},
+		{name: "read error", r: io.MultiReader(bytes.NewReader(content[:5]), iotest.ErrReader(errRead)), wantErr: errRead},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			dest := filepath.Join(dir, "root.img")
+
+			if err := writeVerified(desc, tt.r, dest); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("writeVerified() = %v, want %v", err, tt.wantErr)
+			}
+
+			b, err := os.ReadFile(dest)
+			switch {
+			case tt.wantErr == nil && !bytes.Equal(b, content):
+				t.Errorf("written blob = %q, %v, want %q", b, err, content)
+			case tt.wantErr != nil && !errors.Is(err, os.ErrNotExist):
+				t.Errorf("written blob after a failure = %q, %v, want none", b, err)
+			}
+
+			// No temporary file is left behind.
+			if entries, _ := os.ReadDir(dir); len(entries) > 1 || (tt.wantErr != nil && len(entries) != 0) {
+				t.Errorf("directory holds %d entries, want only the verified blob", len(entries))
+			}
+		})
+	}
+}
+
+func TestProgressReader(t *testing.T) {
+	content := bytes.Repeat([]byte("gokrazy"), 1000)
+
+	var reports []Progress
+	r := newProgressReader(iotest.HalfReader(bytes.NewReader(content[100:])), "root.img", 100, int64(len(content)),
+		func(p Progress) { reports = append(reports, p) })
+
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reports) == 0 {
+		t.Fatal("no progress reported")
+	}
+
+	// The completion is reported once, at the end.
+	last := reports[len(reports)-1]
+	if last.Name != "root.img" || last.Done != int64(len(content)) || last.Total != int64(len(content)) {
+		t.Errorf("last progress = %+v, want %d/%d bytes of root.img", last, len(content), len(content))
+	}
+
+	for _, p := range reports[:len(reports)-1] {
+		if p.Done >= p.Total {
+			t.Errorf("progress %+v reported complete before the end", p)
+		}
+	}
+}
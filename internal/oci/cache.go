@@ -0,0 +1,404 @@
+package oci
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const (
+	cacheBlobsDir = "blobs"
+	cacheRefsDir  = "refs"
+)
+
+var cacheDirPermission fs.FileMode = 0755
+
+var (
+	// ErrNotCached denotes the error for content missing from the cache.
+	ErrNotCached = errors.New("not found in the oci cache")
+
+	// ErrDigestMismatch denotes the error for content not matching its digest.
+	ErrDigestMismatch = errors.New("content does not match its digest")
+)
+
+// Cache is a local content-addressed store of OCI blobs,
+// along with the references they were pulled from.
+type Cache struct {
+	// Dir is the root directory of the cache.
+	Dir string
+
+	// verified are the digests of the blobs written or verified by this process,
+	// which Link doesn't verify again.
+	mu       sync.Mutex
+	verified map[digest.Digest]bool
+}
+
+// CacheRef is a reference recorded in the cache.
+type CacheRef struct {
+	// Reference is the OCI reference that was pulled.
+	Reference string `json:"reference"`
+
+	// Descriptor is the descriptor of the manifest the reference resolved to.
+	Descriptor ocispec.Descriptor `json:"descriptor"`
+}
+
+// CacheBlob is a blob stored in the cache.
+type CacheBlob struct {
+	Digest   digest.Digest `json:"digest"`
+	Size     int64         `json:"size"`
+	LastUsed time.Time     `json:"last_used"`
+}
+
+// DefaultCache returns the Cache in the gom directory of the user cache directory.
+func DefaultCache() (*Cache, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, fmt.Errorf("error getting user cache directory: %w", err)
+	}
+
+	return &Cache{Dir: filepath.Join(dir, "gom", "oci")}, nil
+}
+
+func (c *Cache) blobPath(d digest.Digest) string {
+	return filepath.Join(c.Dir, cacheBlobsDir, d.Algorithm().String(), d.Encoded())
+}
+
+func (c *Cache) refPath(ref string) string {
+	sum := sha256.Sum256([]byte(ref))
+
+	return filepath.Join(c.Dir, cacheRefsDir, hex.EncodeToString(sum[:])+".json")
+}
+
+// Has reports whether the blob of the descriptor is in the cache.
+func (c *Cache) Has(desc ocispec.Descriptor) bool {
+	fi, err := os.Stat(c.blobPath(desc.Digest))
+
+	return err == nil && fi.Size() == desc.Size
+}
+
+// ReadAll reads the blob of the descriptor from the cache.
+func (c *Cache) ReadAll(desc ocispec.Descriptor) ([]byte, error) {
+	if !c.Has(desc) {
+		return nil, fmt.Errorf("%w: blob %s", ErrNotCached, desc.Digest)
+	}
+
+	b, err := os.ReadFile(c.blobPath(desc.Digest))
+	if err != nil {
+		return nil, fmt.Errorf("error reading cached blob %s: %w", desc.Digest, err)
+	}
+
+	if desc.Digest.Algorithm().FromBytes(b) != desc.Digest {
+		return nil, fmt.Errorf("%w: cached blob %s", ErrDigestMismatch, desc.Digest)
+	}
+
+	c.touch(desc.Digest)
+
+	return b, nil
+}
+
+// Put streams the blob of the descriptor read from r to the cache, verifying its digest.
+func (c *Cache) Put(desc ocispec.Descriptor, r io.Reader) error {
+	p, err := c.blobFile(desc)
+	if err != nil {
+		return err
+	}
+
+	if err := writeVerified(desc, r, p); err != nil {
+		return err
+	}
+
+	c.markVerified(desc.Digest)
+
+	return nil
+}
+
+// blobFile returns the path the blob of the descriptor is stored at, creating its directory.
+func (c *Cache) blobFile(desc ocispec.Descriptor) (string, error) {
+	p := c.blobPath(desc.Digest)
+	if err := os.MkdirAll(filepath.Dir(p), cacheDirPermission); err != nil {
+		return "", fmt.Errorf("error creating cache directory: %w", err)
+	}
+
+	return p, nil
+}
+
+// Link makes the cached blob of the descriptor available at dest,
+// hard linking it when possible and copying it otherwise.
+// The blob is verified against the descriptor digest first, unless this process wrote or verified it,
+// and removed from the cache if it doesn't match.
+func (c *Cache) Link(desc ocispec.Descriptor, dest string) error {
+	if !c.Has(desc) {
+		return fmt.Errorf("%w: blob %s", ErrNotCached, desc.Digest)
+	}
+
+	if err := c.verify(desc); err != nil {
+		return err
+	}
+
+	c.touch(desc.Digest)
+
+	src := c.blobPath(desc.Digest)
+	if err := os.Link(src, dest); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("error opening cached blob %s: %w", desc.Digest, err)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, blobFilePermission)
+	if err != nil {
+		return fmt.Errorf("error creating file %s: %w", dest, err)
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, in); err != nil {
+		return fmt.Errorf("error copying cached blob %s to %s: %w", desc.Digest, dest, err)
+	}
+
+	return out.Close()
+}
+
+// SetRef records the manifest descriptor the reference resolved to.
+func (c *Cache) SetRef(ref string, desc ocispec.Descriptor) error {
+	b, err := json.Marshal(CacheRef{Reference: ref, Descriptor: desc})
+	if err != nil {
+		return fmt.Errorf("error encoding cache reference: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(c.Dir, cacheRefsDir), cacheDirPermission); err != nil {
+		return fmt.Errorf("error creating cache directory: %w", err)
+	}
+
+	if err := os.WriteFile(c.refPath(ref), b, blobFilePermission); err != nil {
+		return fmt.Errorf("error writing cache reference %s: %w", ref, err)
+	}
+
+	return nil
+}
+
+// Ref returns the manifest descriptor the reference last resolved to.
+func (c *Cache) Ref(ref string) (ocispec.Descriptor, error) {
+	b, err := os.ReadFile(c.refPath(ref))
+	if errors.Is(err, fs.ErrNotExist) {
+		return ocispec.Descriptor{}, fmt.Errorf("%w: reference %s", ErrNotCached, ref)
+	}
+
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("error reading cache reference %s: %w", ref, err)
+	}
+
+	var cr CacheRef
+	if err := json.Unmarshal(b, &cr); err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("error decoding cache reference %s: %w", ref, err)
+	}
+
+	return cr.Descriptor, nil
+}
+
+// Refs returns the references recorded in the cache, sorted.
+func (c *Cache) Refs() ([]CacheRef, error) {
+	files, err := filepath.Glob(filepath.Join(c.Dir, cacheRefsDir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("error listing cache references: %w", err)
+	}
+
+	refs := make([]CacheRef, 0, len(files))
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("error reading cache reference %s: %w", f, err)
+		}
+
+		var cr CacheRef
+		if err := json.Unmarshal(b, &cr); err != nil {
+			return nil, fmt.Errorf("error decoding cache reference %s: %w", f, err)
+		}
+
+		refs = append(refs, cr)
+	}
+
+	sort.Slice(refs, func(i, j int) bool { return refs[i].Reference < refs[j].Reference })
+
+	return refs, nil
+}
+
+// Blobs returns the blobs stored in the cache, least recently used first.
+func (c *Cache) Blobs() ([]CacheBlob, error) {
+	var blobs []CacheBlob
+
+	root := filepath.Join(c.Dir, cacheBlobsDir)
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && p == root {
+			return fs.SkipDir
+		}
+
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+
+		dgst := digest.Digest(filepath.Dir(rel) + ":" + filepath.Base(rel))
+		if dgst.Validate() != nil {
+			// Leftover temporary files.
+			return nil
+		}
+
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		blobs = append(blobs, CacheBlob{Digest: dgst, Size: fi.Size(), LastUsed: fi.ModTime()})
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing cache blobs: %w", err)
+	}
+
+	sort.Slice(blobs, func(i, j int) bool { return blobs[i].LastUsed.Before(blobs[j].LastUsed) })
+
+	return blobs, nil
+}
+
+// Prune removes the blobs not used since before, along with the references
+// whose manifest was removed. It returns the removed blobs.
+func (c *Cache) Prune(before time.Time) ([]CacheBlob, error) {
+	blobs, err := c.Blobs()
+	if err != nil {
+		return nil, err
+	}
+
+	var pruned []CacheBlob
+	for _, b := range blobs {
+		if !b.LastUsed.Before(before) {
+			continue
+		}
+
+		if err := os.Remove(c.blobPath(b.Digest)); err != nil {
+			return pruned, fmt.Errorf("error removing cached blob %s: %w", b.Digest, err)
+		}
+
+		pruned = append(pruned, b)
+	}
+
+	if err := c.pruneLeftovers(before); err != nil {
+		return pruned, err
+	}
+
+	refs, err := c.Refs()
+	if err != nil {
+		return pruned, err
+	}
+
+	for _, r := range refs {
+		if c.Has(r.Descriptor) {
+			continue
+		}
+
+		if err := os.Remove(c.refPath(r.Reference)); err != nil {
+			return pruned, fmt.Errorf("error removing cache reference %s: %w", r.Reference, err)
+		}
+	}
+
+	return pruned, nil
+}
+
+// pruneLeftovers removes the partial downloads and temporary files
+// of the blobs directory not modified since before.
+func (c *Cache) pruneLeftovers(before time.Time) error {
+	root := filepath.Join(c.Dir, cacheBlobsDir)
+
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && p == root {
+			return fs.SkipDir
+		}
+
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+
+		if digest.Digest(filepath.Dir(rel)+":"+filepath.Base(rel)).Validate() == nil {
+			return nil
+		}
+
+		fi, err := d.Info()
+		if err != nil || !fi.ModTime().Before(before) {
+			return err
+		}
+
+		return os.Remove(p)
+	})
+	if err != nil {
+		return fmt.Errorf("error removing leftover cache files: %w", err)
+	}
+
+	return nil
+}
+
+// markVerified records the blob with the digest matches it.
+func (c *Cache) markVerified(d digest.Digest) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.verified == nil {
+		c.verified = make(map[digest.Digest]bool)
+	}
+
+	c.verified[d] = true
+}
+
+// verify checks the cached blob of the descriptor matches its digest, removing it otherwise.
+func (c *Cache) verify(desc ocispec.Descriptor) error {
+	c.mu.Lock()
+	verified := c.verified[desc.Digest]
+	c.mu.Unlock()
+
+	if verified {
+		return nil
+	}
+
+	if err := verifyFile(desc, c.blobPath(desc.Digest)); err != nil {
+		if errors.Is(err, ErrDigestMismatch) {
+			os.Remove(c.blobPath(desc.Digest))
+
+			return fmt.Errorf("%w: cached blob %s", ErrDigestMismatch, desc.Digest)
+		}
+
+		return err
+	}
+
+	c.markVerified(desc.Digest)
+
+	return nil
+}
+
+// touch records the blob was just used.
+func (c *Cache) touch(d digest.Digest) {
+	now := time.Now()
+	_ = os.Chtimes(c.blobPath(d), now, now)
+}
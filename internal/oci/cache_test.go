@@ -0,0 +1,157 @@
+package oci
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func testDescriptor(content []byte) ocispec.Descriptor {
+	return ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageLayer,
+		Digest:    digest.FromBytes(content),
+		Size:      int64(len(content)),
+	}
+}
+
+func TestCache(t *testing.T) {
+	content := []byte("gokrazy root")
+	desc := testDescriptor(content)
+
+	c := &Cache{Dir: t.TempDir()}
+
+	// Miss.
+	if c.Has(desc) {
+		t.Error("Has() of a blob not put = true, want false")
+	}
+
+	if _, err := c.ReadAll(desc); !errors.Is(err, ErrNotCached) {
+		t.Errorf("ReadAll() of a blob not put = %v, want %v", err, ErrNotCached)
+	}
+
+	if err := c.Link(desc, filepath.Join(t.TempDir(), "blob")); !errors.Is(err, ErrNotCached) {
+		t.Errorf("Link() of a blob not put = %v, want %v", err, ErrNotCached)
+	}
+
+	// Content not matching the descriptor is not cached.
+	if err := c.Put(desc, bytes.NewReader([]byte("gokrazy boot"))); !errors.Is(err, ErrDigestMismatch) {
+		t.Errorf("Put() of other content = %v, want %v", err, ErrDigestMismatch)
+	}
+
+	if c.Has(desc) {
+		t.Error("Has() after a failed Put() = true, want false")
+	}
+
+	// Hit.
+	if err := c.Put(desc, bytes.NewReader(content)); err != nil {
+		t.Fatalf("Put() = %v, want nil", err)
+	}
+
+	if !c.Has(desc) {
+		t.Error("Has() of a put blob = false, want true")
+	}
+
+	if b, err := c.ReadAll(desc); err != nil || !bytes.Equal(b, content) {
+		t.Errorf("ReadAll() = %q, %v, want %q, nil", b, err, content)
+	}
+
+	dest := filepath.Join(t.TempDir(), "blob")
+	if err := c.Link(desc, dest); err != nil {
+		t.Fatalf("Link() = %v, want nil", err)
+	}
+
+	if b, err := os.ReadFile(dest); err != nil || !bytes.Equal(b, content) {
+		t.Errorf("linked blob = %q, %v, want %q, nil", b, err, content)
+	}
+
+	// A blob of another size is a miss.
+	other := desc
+	other.Size++
+	if c.Has(other) {
+		t.Error("Has() of a descriptor with another size = true, want false")
+	}
+}
+
+func TestCacheCorruptBlob(t *testing.T) {
+	content := []byte("gokrazy root")
+	desc := testDescriptor(content)
+
+	dir := t.TempDir()
+	if err := (&Cache{Dir: dir}).Put(desc, bytes.NewReader(content)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Corrupt the blob, keeping its size, as seen by another gom process.
+	c := &Cache{Dir: dir}
+	if err := os.WriteFile(c.blobPath(desc.Digest), []byte("gokrazy boot"), blobFilePermission); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.ReadAll(desc); !errors.Is(err, ErrDigestMismatch) {
+		t.Errorf("ReadAll() of a corrupt blob = %v, want %v", err, ErrDigestMismatch)
+	}
+
+	dest := filepath.Join(t.TempDir(), "blob")
+	if err := c.Link(desc, dest); !errors.Is(err, ErrDigestMismatch) {
+		t.Errorf("Link() of a corrupt blob = %v, want %v", err, ErrDigestMismatch)
+	}
+
+	if _, err := os.Stat(dest); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("destination of the corrupt blob: %v, want not created", err)
+	}
+
+	if c.Has(desc) {
+		t.Error("Has() after linking a corrupt blob = true, want it removed")
+	}
+}
+
+func TestCacheRefs(t *testing.T) {
+	c := &Cache{Dir: t.TempDir()}
+
+	const ref = "ghcr.io/gokrazy/gokrazy:latest"
+
+	if _, err := c.Ref(ref); !errors.Is(err, ErrNotCached) {
+		t.Errorf("Ref() of a reference not set = %v, want %v", err, ErrNotCached)
+	}
+
+	manifest := []byte(`{"schemaVersion":2}`)
+	desc := testDescriptor(manifest)
+
+	if err := c.Put(desc, bytes.NewReader(manifest)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.SetRef(ref, desc); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := c.Ref(ref); err != nil || got.Digest != desc.Digest {
+		t.Errorf("Ref() = %v, %v, want %v, nil", got.Digest, err, desc.Digest)
+	}
+
+	refs, err := c.Refs()
+	if err != nil || len(refs) != 1 || refs[0].Reference != ref {
+		t.Errorf("Refs() = %+v, %v, want %s", refs, err, ref)
+	}
+
+	// Pruning the manifest also removes the reference to it.
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(c.blobPath(desc.Digest), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	pruned, err := c.Prune(time.Now().Add(-time.Minute))
+	if err != nil || len(pruned) != 1 || pruned[0].Digest != desc.Digest {
+		t.Fatalf("Prune() = %+v, %v, want %s pruned", pruned, err, desc.Digest)
+	}
+
+	if _, err := c.Ref(ref); !errors.Is(err, ErrNotCached) {
+		t.Errorf("Ref() of a pruned reference = %v, want %v", err, ErrNotCached)
+	}
+}
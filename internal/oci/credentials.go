@@ -0,0 +1,221 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"oras.land/oras-go/v2/registry/remote/auth"
+)
+
+const (
+	// EnvUser and EnvPassword are the environment variables
+	// holding the OCI registry credentials.
+	EnvUser     = "GOM_OCI_USER"
+	EnvPassword = "GOM_OCI_PASSWORD"
+
+	// envDockerConfig is the environment variable overriding the docker config directory.
+	envDockerConfig = "DOCKER_CONFIG"
+
+	dockerConfigDir  = ".docker"
+	dockerConfigFile = "config.json"
+
+	// dockerHubConfigKey is the key of Docker Hub in the docker config.
+	dockerHubConfigKey = "https://index.docker.io/v1/"
+
+	credentialHelperPrefix = "docker-credential-"
+
+	// credentialHelperNotFound is the error message of credential helpers for unknown servers.
+	credentialHelperNotFound = "credentials not found in native keychain"
+
+	// credentialHelperTokenUser is the username credential helpers return along with identity tokens.
+	credentialHelperTokenUser = "<token>"
+)
+
+// ErrInvalidDockerConfig denotes the error for a docker config that can't be used.
+var ErrInvalidDockerConfig = errors.New("invalid docker config")
+
+// dockerConfig is the subset of the docker config.json used for registry authentication.
+type dockerConfig struct {
+	Auths       map[string]dockerAuth `json:"auths"`
+	CredsStore  string                `json:"credsStore"`
+	CredHelpers map[string]string     `json:"credHelpers"`
+}
+
+type dockerAuth struct {
+	Auth          string `json:"auth"`
+	Username      string `json:"username"`
+	Password      string `json:"password"`
+	IdentityToken string `json:"identitytoken"`
+}
+
+// credentialFunc returns the auth.Client credential function for the given credentials:
+// they are used as is if any is set, and resolved with DefaultCredential otherwise.
+func credentialFunc(username, password string) func(context.Context, string) (auth.Credential, error) {
+	return func(ctx context.Context, hostport string) (auth.Credential, error) {
+		if username != "" || password != "" {
+			return auth.Credential{
+				Username: username,
+				Password: password,
+			}, nil
+		}
+
+		return DefaultCredential(ctx, hostport)
+	}
+}
+
+// DefaultCredential returns the credential for the registry at hostport from,
+// in order, the GOM_OCI_USER and GOM_OCI_PASSWORD environment variables
+// and the docker config (its credential helpers, auths and then credentials store).
+// It returns an empty credential if none is found.
+func DefaultCredential(ctx context.Context, hostport string) (auth.Credential, error) {
+	if username, password := os.Getenv(EnvUser), os.Getenv(EnvPassword); username != "" || password != "" {
+		return auth.Credential{
+			Username: username,
+			Password: password,
+		}, nil
+	}
+
+	cfg, err := readDockerConfig()
+	if err != nil {
+		return auth.EmptyCredential, err
+	}
+
+	keys := dockerConfigKeys(hostport)
+
+	for _, key := range keys {
+		if helper, ok := cfg.CredHelpers[key]; ok {
+			return helperCredential(ctx, helper, key)
+		}
+	}
+
+	for _, key := range keys {
+		// With a credentials store, docker login leaves empty auths entries.
+		if a, ok := cfg.Auths[key]; ok && !a.empty() {
+			return a.credential(key)
+		}
+	}
+
+	if cfg.CredsStore == "" {
+		return auth.EmptyCredential, nil
+	}
+
+	for _, key := range keys {
+		c, err := helperCredential(ctx, cfg.CredsStore, key)
+		if err != nil || c != auth.EmptyCredential {
+			return c, err
+		}
+	}
+
+	return auth.EmptyCredential, nil
+}
+
+// readDockerConfig reads the docker config.json, returning an empty one if it doesn't exist.
+func readDockerConfig() (dockerConfig, error) {
+	dir := os.Getenv(envDockerConfig)
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return dockerConfig{}, nil //nolint:nilerr
+		}
+
+		dir = filepath.Join(home, dockerConfigDir)
+	}
+
+	p := filepath.Join(dir, dockerConfigFile)
+
+	b, err := os.ReadFile(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return dockerConfig{}, nil
+	}
+
+	if err != nil {
+		return dockerConfig{}, fmt.Errorf("error reading docker config %s: %w", p, err)
+	}
+
+	var cfg dockerConfig
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return dockerConfig{}, fmt.Errorf("%w: %s: %w", ErrInvalidDockerConfig, p, err)
+	}
+
+	return cfg, nil
+}
+
+// dockerConfigKeys returns the keys the registry at hostport can have in the docker config.
+func dockerConfigKeys(hostport string) []string {
+	switch hostport {
+	case "docker.io", "index.docker.io", "registry-1.docker.io":
+		return []string{dockerHubConfigKey, "docker.io", "index.docker.io", "registry-1.docker.io"}
+	}
+
+	return []string{hostport, "https://" + hostport, "http://" + hostport}
+}
+
+// empty reports whether the docker config auths entry holds no credential.
+func (a dockerAuth) empty() bool {
+	return a == dockerAuth{}
+}
+
+// credential decodes the credential of a docker config auths entry.
+func (a dockerAuth) credential(key string) (auth.Credential, error) {
+	if a.IdentityToken != "" {
+		return auth.Credential{RefreshToken: a.IdentityToken}, nil
+	}
+
+	if a.Auth == "" {
+		return auth.Credential{Username: a.Username, Password: a.Password}, nil
+	}
+
+	b, err := base64.StdEncoding.DecodeString(a.Auth)
+	if err != nil {
+		return auth.EmptyCredential, fmt.Errorf("%w: auth of %s: %w", ErrInvalidDockerConfig, key, err)
+	}
+
+	username, password, ok := strings.Cut(string(b), ":")
+	if !ok {
+		return auth.EmptyCredential, fmt.Errorf("%w: auth of %s is not in the user:password form", ErrInvalidDockerConfig, key)
+	}
+
+	return auth.Credential{Username: username, Password: password}, nil
+}
+
+// helperCredential gets the credential for the server from the docker-credential-<helper> program.
+func helperCredential(ctx context.Context, helper, server string) (auth.Credential, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, credentialHelperPrefix+helper, "get")
+	cmd.Stdin = strings.NewReader(server)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if strings.Contains(stdout.String()+stderr.String(), credentialHelperNotFound) {
+			return auth.EmptyCredential, nil
+		}
+
+		return auth.EmptyCredential, fmt.Errorf("error running %s%s for %s: %w: %s",
+			credentialHelperPrefix, helper, server, err, strings.TrimSpace(stderr.String()))
+	}
+
+	var out struct {
+		Username string `json:"Username"`
+		Secret   string `json:"Secret"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &out); err != nil {
+		return auth.EmptyCredential, fmt.Errorf("error decoding %s%s output: %w", credentialHelperPrefix, helper, err)
+	}
+
+	if out.Username == credentialHelperTokenUser {
+		return auth.Credential{RefreshToken: out.Secret}, nil
+	}
+
+	return auth.Credential{Username: out.Username, Password: out.Secret}, nil
+}
@@ -0,0 +1,172 @@
+package oci
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"oras.land/oras-go/v2/registry/remote/auth"
+)
+
+// testCredentialHelper is a docker-credential-* helper knowing the credentials of a few servers.
+const testCredentialHelper = `#!/bin/sh
+read -r server
+case "$server" in
+store.example.com) echo '{"Username":"store","Secret":"store-secret"}' ;;
+https://scheme.example.com) echo '{"Username":"scheme","Secret":"scheme-secret"}' ;;
+helper.example.com) echo '{"Username":"<token>","Secret":"helper-token"}' ;;
+broken.example.com) echo 'boom' >&2; exit 2 ;;
+*) echo 'credentials not found in native keychain'; exit 1 ;;
+esac
+`
+
+func TestDefaultCredential(t *testing.T) {
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, credentialHelperPrefix+"test"), []byte(testCredentialHelper), 0700); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	basic := func(user, password string) string {
+		return base64.StdEncoding.EncodeToString([]byte(user + ":" + password))
+	}
+
+	withStore := `{
+		"auths": {
+			"auths.example.com": {"auth": "` + basic("auths", "auths-secret") + `"},
+			"store.example.com": {}
+		},
+		"credsStore": "test",
+		"credHelpers": {"helper.example.com": "test", "auths.example.com:5000": "test"}
+	}`
+
+	tests := []struct {
+		name     string
+		config   string
+		env      [2]string
+		hostport string
+		want     auth.Credential
+		wantErr  error
+	}{
+		{
+			name:     "environment",
+			config:   withStore,
+			env:      [2]string{"env", "env-secret"},
+			hostport: "auths.example.com",
+			want:     auth.Credential{Username: "env", Password: "env-secret"},
+		},
+		{
+			name:     "auths",
+			config:   withStore,
+			hostport: "auths.example.com",
+			want:     auth.Credential{Username: "auths", Password: "auths-secret"},
+		},
+		{
+			name:     "auths with scheme",
+			config:   `{"auths": {"https://localhost:5000": {"username": "local", "password": "local-secret"}}}`,
+			hostport: "localhost:5000",
+			want:     auth.Credential{Username: "local", Password: "local-secret"},
+		},
+		{
+			name:     "docker hub",
+			config:   `{"auths": {"https://index.docker.io/v1/": {"auth": "` + basic("hub", "hub-secret") + `"}}}`,
+			hostport: "registry-1.docker.io",
+			want:     auth.Credential{Username: "hub", Password: "hub-secret"},
+		},
+		{
+			name:     "identity token",
+			config:   `{"auths": {"ghcr.io": {"identitytoken": "token"}}}`,
+			hostport: "ghcr.io",
+			want:     auth.Credential{RefreshToken: "token"},
+		},
+		{
+			name:     "credential helper",
+			config:   withStore,
+			hostport: "helper.example.com",
+			want:     auth.Credential{RefreshToken: "helper-token"},
+		},
+		{
+			name:     "credential helper without credentials",
+			config:   withStore,
+			hostport: "auths.example.com:5000",
+		},
+		{
+			name:     "credentials store for an empty auths entry",
+			config:   withStore,
+			hostport: "store.example.com",
+			want:     auth.Credential{Username: "store", Password: "store-secret"},
+		},
+		{
+			name:     "credentials store without auths entry",
+			config:   `{"credsStore": "test"}`,
+			hostport: "store.example.com",
+			want:     auth.Credential{Username: "store", Password: "store-secret"},
+		},
+		{
+			name:     "credentials store with scheme",
+			config:   `{"credsStore": "test"}`,
+			hostport: "scheme.example.com",
+			want:     auth.Credential{Username: "scheme", Password: "scheme-secret"},
+		},
+		{
+			name:     "credentials store without credentials",
+			config:   withStore,
+			hostport: "unknown.example.com",
+		},
+		{
+			name:     "credentials store failure",
+			config:   withStore,
+			hostport: "broken.example.com",
+			wantErr:  errors.New("any"), //nolint:goerr113
+		},
+		{
+			name:     "no config",
+			hostport: "ghcr.io",
+		},
+		{
+			name:     "invalid auth",
+			config:   `{"auths": {"ghcr.io": {"auth": "not base64"}}}`,
+			hostport: "ghcr.io",
+			wantErr:  ErrInvalidDockerConfig,
+		},
+		{
+			name:     "invalid config",
+			config:   `{"auths": [}`,
+			hostport: "ghcr.io",
+			wantErr:  ErrInvalidDockerConfig,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			t.Setenv(envDockerConfig, dir)
+			t.Setenv(EnvUser, tt.env[0])
+			t.Setenv(EnvPassword, tt.env[1])
+
+			if tt.config != "" {
+				if err := os.WriteFile(filepath.Join(dir, dockerConfigFile), []byte(tt.config), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := DefaultCredential(context.Background(), tt.hostport)
+
+			switch {
+			case tt.wantErr == nil && err != nil:
+				t.Fatalf("DefaultCredential() = %v, want nil", err)
+			case tt.wantErr != nil && err == nil:
+				t.Fatalf("DefaultCredential() = %+v, want an error", got)
+			case errors.Is(tt.wantErr, ErrInvalidDockerConfig) && !errors.Is(err, ErrInvalidDockerConfig):
+				t.Fatalf("DefaultCredential() = %v, want %v", err, ErrInvalidDockerConfig)
+			}
+
+			if got != tt.want {
+				t.Errorf("DefaultCredential() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,329 @@
+package oci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/errdef"
+)
+
+const (
+	// DefaultConcurrency is the default number of blobs downloaded at once.
+	DefaultConcurrency = 3
+
+	// DefaultRetries is the default number of times a failed blob download is retried.
+	DefaultRetries = 5
+
+	// partialSuffix is the suffix of the files blobs are downloaded to until they are complete,
+	// kept across retries and pulls to resume the download from.
+	partialSuffix = ".partial"
+
+	// lockPollInterval is the interval the lock of a partial file held by another process is polled at.
+	lockPollInterval = 200 * time.Millisecond
+)
+
+// The backoff between the retries of a failed blob download, doubled after each retry.
+var (
+	retryInitialBackoff = time.Second
+	retryMaxBackoff     = 30 * time.Second
+)
+
+// download downloads the blob of the descriptor from src to dest, retrying with backoff
+// on failures and resuming from the bytes downloaded so far with range requests
+// when src supports them. The content is verified against the descriptor once complete,
+// and downloaded again once from the start if it doesn't match.
+// The partial file is locked, so that the processes downloading the same blob wait for each other.
+func download(ctx context.Context, src content.Fetcher, desc ocispec.Descriptor, name, dest string,
+	retries int, progress ProgressFunc,
+) error {
+	partial := dest + partialSuffix
+
+	f, err := lockPartial(ctx, partial, name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Another process may have completed the blob while this one waited for the lock.
+	if err := verifyFile(desc, dest); err == nil {
+		os.Remove(partial)
+
+		return nil
+	}
+
+	for restarted := false; ; restarted = true {
+		if err := downloadWithRetries(ctx, src, desc, name, f, retries, progress); err != nil {
+			return err
+		}
+
+		err := verifyPartial(desc, f, partial, dest)
+		if err == nil || !errors.Is(err, ErrDigestMismatch) {
+			return err
+		}
+
+		if restarted {
+			os.Remove(partial)
+
+			return err
+		}
+
+		log.Printf("blob %s doesn't match its digest, downloading it again\n", name)
+	}
+}
+
+// downloadWithRetries downloads the missing content of the blob of the descriptor to the partial file,
+// retrying with backoff on failures.
+func downloadWithRetries(ctx context.Context, src content.Fetcher, desc ocispec.Descriptor, name string,
+	f *os.File, retries int, progress ProgressFunc,
+) error {
+	backoff := retryInitialBackoff
+
+	for attempt := 0; ; attempt++ {
+		err := downloadPartial(ctx, src, desc, name, f, progress)
+		if err == nil {
+			return nil
+		}
+
+		if attempt >= retries || !isRetryable(ctx, err) {
+			return err
+		}
+
+		log.Printf("error downloading blob %s, retrying in %s (%d/%d): %v\n", name, backoff, attempt+1, retries, err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > retryMaxBackoff {
+			backoff = retryMaxBackoff
+		}
+	}
+}
+
+// isRetryable reports whether a failed download can succeed if retried.
+func isRetryable(ctx context.Context, err error) bool {
+	return ctx.Err() == nil && !errors.Is(err, errdef.ErrNotFound) && !errors.Is(err, ErrInvalidImageLayout)
+}
+
+// lockPartial opens the partial file and locks it, waiting for the other processes holding it.
+func lockPartial(ctx context.Context, partial, name string) (*os.File, error) {
+	waiting := false
+
+	for {
+		f, err := os.OpenFile(partial, os.O_RDWR|os.O_CREATE, blobFilePermission)
+		if err != nil {
+			return nil, fmt.Errorf("error opening partial blob %s: %w", partial, err)
+		}
+
+		err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			// The partial file may have been completed and moved, or removed, while waiting for the lock.
+			locked, err1 := f.Stat()
+			current, err2 := os.Stat(partial)
+
+			if err1 == nil && err2 == nil && os.SameFile(locked, current) {
+				return f, nil
+			}
+
+			f.Close()
+
+			continue
+		}
+
+		f.Close()
+
+		if !errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, fmt.Errorf("error locking partial blob %s: %w", partial, err)
+		}
+
+		if !waiting {
+			log.Printf("waiting for another download of blob %s\n", name)
+			waiting = true
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+// downloadPartial appends the missing content of the blob of the descriptor to the partial file,
+// starting over if src can't seek to the end of the partial content.
+func downloadPartial(ctx context.Context, src content.Fetcher, desc ocispec.Descriptor, name string, f *os.File,
+	progress ProgressFunc,
+) error {
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return fmt.Errorf("error reading partial blob %s: %w", f.Name(), err)
+	}
+
+	if offset > desc.Size {
+		if offset, err = truncate(f); err != nil {
+			return fmt.Errorf("error truncating partial blob %s: %w", f.Name(), err)
+		}
+	}
+
+	if offset == desc.Size {
+		return nil
+	}
+
+	rc, err := src.Fetch(ctx, desc)
+	if err != nil {
+		return fmt.Errorf("error fetching blob %s: %w", desc.Digest, err)
+	}
+	defer rc.Close()
+
+	if offset > 0 {
+		seeker, ok := rc.(io.Seeker)
+		if ok {
+			_, err = seeker.Seek(offset, io.SeekStart)
+		}
+
+		if ok && err == nil {
+			log.Printf("resuming blob %s at %s\n", name, byteCountIEC(offset))
+		} else {
+			log.Printf("can't resume blob %s, downloading it again\n", name)
+
+			if offset, err = truncate(f); err != nil {
+				return fmt.Errorf("error truncating partial blob %s: %w", f.Name(), err)
+			}
+		}
+	}
+
+	r := newProgressReader(rc, name, offset, desc.Size, progress)
+
+	n, err := io.Copy(f, io.LimitReader(r, desc.Size-offset))
+	if err == nil && n < desc.Size-offset {
+		err = io.ErrUnexpectedEOF
+	}
+
+	if err != nil {
+		return fmt.Errorf("error downloading blob %s: %w", desc.Digest, err)
+	}
+
+	return nil
+}
+
+// truncate empties the file, returning the new offset.
+func truncate(f *os.File) (int64, error) {
+	if err := f.Truncate(0); err != nil {
+		return 0, err
+	}
+
+	return f.Seek(0, io.SeekStart)
+}
+
+// verifyPartial moves the locked partial file to dest if its content matches the descriptor,
+// and empties it otherwise.
+func verifyPartial(desc ocispec.Descriptor, f *os.File, partial, dest string) error {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("error reading partial blob %s: %w", partial, err)
+	}
+
+	if err := verifyContent(desc, f); err != nil {
+		if _, err := truncate(f); err != nil {
+			return fmt.Errorf("error truncating partial blob %s: %w", partial, err)
+		}
+
+		return err
+	}
+
+	if err := os.Rename(partial, dest); err != nil {
+		return fmt.Errorf("error moving blob %s to %s: %w", desc.Digest, dest, err)
+	}
+
+	return nil
+}
+
+// verifyFile checks the content of the file at path matches the descriptor.
+func verifyFile(desc ocispec.Descriptor, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error opening blob %s: %w", path, err)
+	}
+	defer f.Close()
+
+	return verifyContent(desc, f)
+}
+
+// verifyContent checks the content read from r matches the descriptor size and digest.
+func verifyContent(desc ocispec.Descriptor, r io.Reader) error {
+	verifier := desc.Digest.Verifier()
+
+	// Read one byte more than expected to detect oversized content.
+	n, err := io.Copy(verifier, io.LimitReader(r, desc.Size+1))
+	if err != nil {
+		return fmt.Errorf("error reading blob %s: %w", desc.Digest, err)
+	}
+
+	if n != desc.Size || !verifier.Verified() {
+		return fmt.Errorf("%w: blob %s", ErrDigestMismatch, desc.Digest)
+	}
+
+	return nil
+}
+
+// forEachConcurrently calls fn for each of the layers, at most concurrency at once.
+// After the first error no other calls are started, and the context of the running ones is canceled.
+// It returns the first error.
+func forEachConcurrently(ctx context.Context, layers []ocispec.Descriptor, concurrency int,
+	fn func(context.Context, ocispec.Descriptor) error,
+) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+
+	sem := make(chan struct{}, concurrency)
+
+	for _, layer := range layers {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+
+		go func(layer ocispec.Descriptor) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := fn(ctx, layer); err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(layer)
+	}
+
+	wg.Wait()
+
+	if firstErr == nil {
+		return ctx.Err()
+	}
+
+	return firstErr
+}
@@ -0,0 +1,286 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/errdef"
+)
+
+var errConnectionReset = errors.New("connection reset")
+
+// testFetch describes the outcome of a fetch of the testFetcher.
+type testFetch struct {
+	err     error // returned by Fetch
+	cut     int64 // bytes read before the stream fails, if positive
+	corrupt bool  // whether the content is corrupt
+}
+
+// testFetcher fetches its content, with the outcomes of its plan for the first fetches.
+type testFetcher struct {
+	content  []byte
+	seekable bool
+	plan     []testFetch
+
+	mu      sync.Mutex
+	fetches int
+	offsets []int64 // the offsets the seekable fetches were resumed at
+}
+
+func (f *testFetcher) Fetch(_ context.Context, _ ocispec.Descriptor) (io.ReadCloser, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var fetch testFetch
+	if f.fetches < len(f.plan) {
+		fetch = f.plan[f.fetches]
+	}
+
+	f.fetches++
+
+	if fetch.err != nil {
+		return nil, fetch.err
+	}
+
+	content := f.content
+	if fetch.corrupt {
+		content = bytes.ToUpper(content)
+	}
+
+	r := &testBlobReader{r: bytes.NewReader(content), cut: fetch.cut, fetcher: f}
+	if !f.seekable {
+		return io.NopCloser(r), nil
+	}
+
+	return r, nil
+}
+
+type testBlobReader struct {
+	r       *bytes.Reader
+	cut     int64
+	fetcher *testFetcher
+}
+
+func (r *testBlobReader) Read(b []byte) (int, error) {
+	if r.cut > 0 {
+		read := r.r.Size() - int64(r.r.Len())
+		if read >= r.cut {
+			return 0, errConnectionReset
+		}
+
+		if int64(len(b)) > r.cut-read {
+			b = b[:r.cut-read]
+		}
+	}
+
+	return r.r.Read(b)
+}
+
+func (r *testBlobReader) Seek(offset int64, whence int) (int64, error) {
+	r.fetcher.mu.Lock()
+	r.fetcher.offsets = append(r.fetcher.offsets, offset)
+	r.fetcher.mu.Unlock()
+
+	if r.cut > 0 {
+		r.cut += offset
+	}
+
+	return r.r.Seek(offset, whence)
+}
+
+func (r *testBlobReader) Close() error {
+	return nil
+}
+
+func TestDownload(t *testing.T) {
+	saved := retryInitialBackoff
+	retryInitialBackoff = time.Millisecond
+	t.Cleanup(func() { retryInitialBackoff = saved })
+
+	content := []byte("gokrazy root file system")
+	desc := testDescriptor(content)
+
+	notFound := fmt.Errorf("%s: %w", desc.Digest, errdef.ErrNotFound)
+
+	tests := []struct {
+		name        string
+		partial     []byte
+		seekable    bool
+		plan        []testFetch
+		retries     int
+		wantErr     error
+		wantPartial bool // whether the partial blob is kept to resume from
+		wantFetches int
+		wantOffsets []int64
+	}{
+		{name: "complete", wantFetches: 1},
+		{name: "resume partial", partial: content[:10], seekable: true, wantFetches: 1, wantOffsets: []int64{10}},
+		{name: "restart partial", partial: content[:10], wantFetches: 1},
+		{name: "complete partial", partial: content, wantFetches: 0},
+		{name: "oversized partial", partial: append(append([]byte{}, content...), "garbage"...), wantFetches: 1},
+		{
+			name:        "resume after failure",
+			seekable:    true,
+			plan:        []testFetch{{cut: 7}},
+			retries:     1,
+			wantFetches: 2,
+			wantOffsets: []int64{7},
+		},
+		{
+			name:        "restart after failure",
+			plan:        []testFetch{{cut: 7}},
+			retries:     1,
+			wantFetches: 2,
+		},
+		{
+			name:        "retry fetch",
+			plan:        []testFetch{{err: errConnectionReset}, {err: errConnectionReset}},
+			retries:     2,
+			wantFetches: 3,
+		},
+		{
+			name:        "retries exhausted",
+			plan:        []testFetch{{err: errConnectionReset}, {err: errConnectionReset}},
+			retries:     1,
+			wantErr:     errConnectionReset,
+			wantPartial: true,
+			wantFetches: 2,
+		},
+		{
+			name:        "not found",
+			plan:        []testFetch{{err: notFound}},
+			retries:     3,
+			wantErr:     errdef.ErrNotFound,
+			wantPartial: true,
+			wantFetches: 1,
+		},
+		{
+			name:        "digest mismatch",
+			plan:        []testFetch{{corrupt: true}},
+			wantFetches: 2,
+		},
+		{
+			name:        "persistent digest mismatch",
+			plan:        []testFetch{{corrupt: true}, {corrupt: true}},
+			wantErr:     ErrDigestMismatch,
+			wantFetches: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := filepath.Join(t.TempDir(), "root.squashfs")
+
+			if tt.partial != nil {
+				if err := os.WriteFile(dest+partialSuffix, tt.partial, blobFilePermission); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			src := &testFetcher{content: content, seekable: tt.seekable, plan: tt.plan}
+
+			err := download(context.Background(), src, desc, "root", dest, tt.retries, nil)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("download() = %v, want %v", err, tt.wantErr)
+			}
+
+			if src.fetches != tt.wantFetches {
+				t.Errorf("download() fetched the blob %d times, want %d", src.fetches, tt.wantFetches)
+			}
+
+			if fmt.Sprint(src.offsets) != fmt.Sprint(tt.wantOffsets) {
+				t.Errorf("download() resumed the blob at %v, want %v", src.offsets, tt.wantOffsets)
+			}
+
+			_, err = os.Stat(dest + partialSuffix)
+			if kept := err == nil; kept != tt.wantPartial {
+				t.Errorf("partial blob kept after download() = %t, want %t", kept, tt.wantPartial)
+			}
+
+			got, err := os.ReadFile(dest)
+			switch {
+			case tt.wantErr != nil && !errors.Is(err, os.ErrNotExist):
+				t.Errorf("blob after a failed download() = %v, want %v", err, os.ErrNotExist)
+			case tt.wantErr == nil && !bytes.Equal(got, content):
+				t.Errorf("blob after download() = %q (%v), want %q", got, err, content)
+			}
+		})
+	}
+}
+
+func TestForEachConcurrently(t *testing.T) {
+	layers := make([]ocispec.Descriptor, 10)
+	for i := range layers {
+		layers[i] = testDescriptor([]byte(fmt.Sprint(i)))
+	}
+
+	const concurrency = 3
+
+	var running, maxRunning, calls atomic.Int32
+
+	err := forEachConcurrently(context.Background(), layers, concurrency, func(context.Context, ocispec.Descriptor) error {
+		calls.Add(1)
+
+		n := running.Add(1)
+		defer running.Add(-1)
+
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+
+		time.Sleep(time.Millisecond)
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("forEachConcurrently() = %v, want nil", err)
+	}
+
+	if calls.Load() != int32(len(layers)) {
+		t.Errorf("forEachConcurrently() called fn %d times, want %d", calls.Load(), len(layers))
+	}
+
+	if maxRunning.Load() > concurrency {
+		t.Errorf("forEachConcurrently() ran fn %d at once, want at most %d", maxRunning.Load(), concurrency)
+	}
+}
+
+func TestForEachConcurrentlyError(t *testing.T) {
+	layers := make([]ocispec.Descriptor, 10)
+	for i := range layers {
+		layers[i] = testDescriptor([]byte(fmt.Sprint(i)))
+	}
+
+	var calls atomic.Int32
+
+	err := forEachConcurrently(context.Background(), layers, 2, func(ctx context.Context, _ ocispec.Descriptor) error {
+		if calls.Add(1) == 1 {
+			return errConnectionReset
+		}
+
+		// The other calls run until they are canceled by the first error.
+		<-ctx.Done()
+
+		return ctx.Err()
+	})
+	if !errors.Is(err, errConnectionReset) {
+		t.Fatalf("forEachConcurrently() = %v, want %v", err, errConnectionReset)
+	}
+
+	if calls.Load() > 2 {
+		t.Errorf("forEachConcurrently() called fn %d times after the first error, want at most 2", calls.Load())
+	}
+}
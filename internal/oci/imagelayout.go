@@ -0,0 +1,370 @@
+package oci
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+	orasoci "oras.land/oras-go/v2/content/oci"
+)
+
+const (
+	imageLayoutBlobsDir  = "blobs"
+	imageLayoutIndexFile = "index.json"
+
+	// exportTempPattern is the pattern of the temporary directory archives are exported through.
+	exportTempPattern = "gom-export"
+)
+
+var (
+	// ErrInvalidImageLayout denotes the error for an OCI image layout that can't be read.
+	ErrInvalidImageLayout = errors.New("invalid oci image layout")
+
+	// ErrNotInImageLayout denotes the error for a reference missing from an OCI image layout.
+	ErrNotInImageLayout = errors.New("reference not found in the oci image layout")
+)
+
+// imageLayout is a read-only OCI image layout, either a directory or a tar archive of it.
+type imageLayout struct {
+	// open opens the file at the slash separated path within the layout.
+	open  func(name string) (io.ReadCloser, error)
+	close func() error
+}
+
+// openImageLayout opens the OCI image layout at p, a directory or a tar archive.
+func openImageLayout(p string) (*imageLayout, error) {
+	fi, err := os.Stat(p)
+	if err != nil {
+		return nil, fmt.Errorf("error opening oci image layout %s: %w", p, err)
+	}
+
+	var l *imageLayout
+	if fi.IsDir() {
+		l = &imageLayout{
+			open: func(name string) (io.ReadCloser, error) {
+				return os.Open(filepath.Join(p, filepath.FromSlash(name)))
+			},
+			close: func() error { return nil },
+		}
+	} else {
+		l, err = openImageLayoutArchive(p)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rc, err := l.open(ocispec.ImageLayoutFile)
+	if err != nil {
+		l.close()
+
+		return nil, fmt.Errorf("%w: %s: missing %s file", ErrInvalidImageLayout, p, ocispec.ImageLayoutFile)
+	}
+	defer rc.Close()
+
+	var layout ocispec.ImageLayout
+	if err := json.NewDecoder(rc).Decode(&layout); err != nil || layout.Version != ocispec.ImageLayoutVersion {
+		l.close()
+
+		return nil, fmt.Errorf("%w: %s: unsupported %s file", ErrInvalidImageLayout, p, ocispec.ImageLayoutFile)
+	}
+
+	return l, nil
+}
+
+// openImageLayoutArchive opens the OCI image layout in the tar archive at p,
+// reading its files in place.
+func openImageLayoutArchive(p string) (*imageLayout, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, fmt.Errorf("error opening oci image layout archive %s: %w", p, err)
+	}
+
+	type entry struct {
+		offset int64
+		size   int64
+	}
+
+	entries := make(map[string]entry)
+
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			f.Close()
+
+			return nil, fmt.Errorf("%w: %s: %w", ErrInvalidImageLayout, p, err)
+		}
+
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		// The tar reader doesn't buffer, the file offset is the one of the entry content.
+		offset, err := f.Seek(0, io.SeekCurrent)
+		if err != nil {
+			f.Close()
+
+			return nil, fmt.Errorf("error reading oci image layout archive %s: %w", p, err)
+		}
+
+		entries[path.Clean(strings.TrimPrefix(hdr.Name, "./"))] = entry{offset: offset, size: hdr.Size}
+	}
+
+	return &imageLayout{
+		open: func(name string) (io.ReadCloser, error) {
+			e, ok := entries[name]
+			if !ok {
+				return nil, fmt.Errorf("%s: %w", name, fs.ErrNotExist)
+			}
+
+			return io.NopCloser(io.NewSectionReader(f, e.offset, e.size)), nil
+		},
+		close: f.Close,
+	}, nil
+}
+
+// Fetch fetches the blob of the descriptor.
+func (l *imageLayout) Fetch(_ context.Context, target ocispec.Descriptor) (io.ReadCloser, error) {
+	if err := target.Digest.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidImageLayout, err)
+	}
+
+	rc, err := l.open(path.Join(imageLayoutBlobsDir, target.Digest.Algorithm().String(), target.Digest.Encoded()))
+	if err != nil {
+		return nil, fmt.Errorf("%w: missing blob %s: %w", ErrInvalidImageLayout, target.Digest, err)
+	}
+
+	return rc, nil
+}
+
+// Resolve returns the descriptor of the manifest of the layout index with the reference,
+// a tag or a digest. An empty reference resolves to the only manifest of the index.
+func (l *imageLayout) Resolve(_ context.Context, reference string) (ocispec.Descriptor, error) {
+	rc, err := l.open(imageLayoutIndexFile)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("%w: missing %s file", ErrInvalidImageLayout, imageLayoutIndexFile)
+	}
+	defer rc.Close()
+
+	var index ocispec.Index
+	if err := json.NewDecoder(rc).Decode(&index); err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("%w: %s: %w", ErrInvalidImageLayout, imageLayoutIndexFile, err)
+	}
+
+	if reference == "" {
+		if len(index.Manifests) != 1 {
+			return ocispec.Descriptor{}, fmt.Errorf("%w: no reference specified and %d manifests in the layout",
+				ErrNotInImageLayout, len(index.Manifests))
+		}
+
+		return index.Manifests[0], nil
+	}
+
+	for _, m := range index.Manifests {
+		if m.Annotations[ocispec.AnnotationRefName] == reference || m.Digest.String() == reference {
+			return m, nil
+		}
+	}
+
+	return ocispec.Descriptor{}, fmt.Errorf("%w: %s", ErrNotInImageLayout, reference)
+}
+
+// Close closes the layout.
+func (l *imageLayout) Close() error {
+	return l.close()
+}
+
+// SplitImageLayoutReference splits a path[:tag] or path[@digest] OCI image layout reference.
+func SplitImageLayoutReference(s string) (string, string) {
+	if i := strings.LastIndex(s, "@"); i >= 0 {
+		if _, err := digest.Parse(s[i+1:]); err == nil {
+			return s[:i], s[i+1:]
+		}
+	}
+
+	if i := strings.LastIndex(s, ":"); i >= 0 && !strings.ContainsAny(s[i+1:], `/\`) {
+		return s[:i], s[i+1:]
+	}
+
+	return s, ""
+}
+
+// PullImageLayout pulls the OCI artifacts with the reference (a tag or a digest, or empty
+// if the layout holds a single manifest) from the OCI image layout at layoutPath,
+// a directory or a tar archive, and writes them to the specified dir like Pull does.
+// The Username, Password, PlainHTTP, Cache and Offline options are ignored.
+func PullImageLayout(ctx context.Context, layoutPath, reference, outputDir string, opts PullOptions) (Layout, error) {
+	l, err := openImageLayout(layoutPath)
+	if err != nil {
+		return "", err
+	}
+	defer l.Close()
+
+	log.Printf("pulling blobs from oci image layout %s\n", layoutPath)
+
+	opts.Cache = nil
+
+	return pull(ctx, l, "", reference, outputDir, opts)
+}
+
+// ExportImageLayout copies the OCI artifacts at the OCI Image reference, including all
+// the manifests of image indexes and their cosign signatures, to the OCI image layout directory dir,
+// tagged with the reference tag.
+// Only the Username, Password and PlainHTTP options are used.
+func ExportImageLayout(ctx context.Context, image, dir string, opts PullOptions) (ocispec.Descriptor, error) {
+	ref, err := ParseReference(image)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	repo := newRepository(ref, opts.Username, opts.Password, opts.PlainHTTP)
+
+	store, err := orasoci.New(dir)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("error creating oci image layout %s: %w", dir, err)
+	}
+
+	log.Printf("exporting %s to oci image layout %s\n", ref, dir)
+
+	desc, err := oras.Copy(ctx, repo, ref.Reference, store, ref.Reference, oras.DefaultCopyOptions)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("error exporting %s: %w", ref, err)
+	}
+
+	if err := exportSignatures(ctx, repo, store, desc); err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	return desc, nil
+}
+
+// exportSignatures copies the cosign signatures attached with the tag schema to the manifest
+// of the descriptor, and to the ones of image indexes, so pulls from the layout can verify them.
+func exportSignatures(ctx context.Context, repo oras.ReadOnlyTarget, store *orasoci.Store, desc ocispec.Descriptor) error {
+	manifests := []ocispec.Descriptor{desc}
+
+	manifestContent, err := content.FetchAll(ctx, store, desc)
+	if err != nil {
+		return fmt.Errorf("error reading exported manifest %s: %w", desc.Digest, err)
+	}
+
+	if isIndex(desc, manifestContent) {
+		var index ocispec.Index
+		if err := json.Unmarshal(manifestContent, &index); err != nil {
+			return fmt.Errorf("failed to json decode the oci image index: %w", err)
+		}
+
+		manifests = append(manifests, index.Manifests...)
+	}
+
+	for _, m := range manifests {
+		tag := signatureTag(m.Digest)
+
+		_, err := oras.Copy(ctx, repo, tag, store, tag, oras.DefaultCopyOptions)
+		switch {
+		case err == nil:
+			log.Printf("exported signatures %s\n", tag)
+		case !isNotFound(err):
+			return fmt.Errorf("error exporting signatures %s: %w", tag, err)
+		}
+	}
+
+	return nil
+}
+
+// ExportImageArchive is like ExportImageLayout, writing the layout to the tar archive file.
+func ExportImageArchive(ctx context.Context, image, file string, opts PullOptions) (ocispec.Descriptor, error) {
+	dir, err := os.MkdirTemp(filepath.Dir(file), exportTempPattern)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("error creating temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	desc, err := ExportImageLayout(ctx, image, dir, opts)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	if err := writeTar(dir, file); err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	return desc, nil
+}
+
+// writeTar writes the content of dir to the tar archive file.
+func writeTar(dir, file string) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return fmt.Errorf("error creating archive %s: %w", file, err)
+	}
+	defer f.Close()
+
+	tw := tar.NewWriter(f)
+
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == dir {
+			return err
+		}
+
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+
+		hdr, err := tar.FileInfoHeader(fi, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		src, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+
+		_, err = io.Copy(tw, src)
+
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("error writing archive %s: %w", file, err)
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("error writing archive %s: %w", file, err)
+	}
+
+	return f.Close()
+}
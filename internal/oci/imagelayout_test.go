@@ -0,0 +1,31 @@
+package oci
+
+import "testing"
+
+func TestSplitImageLayoutReference(t *testing.T) {
+	const dgst = "sha256:6c3c624b58dbbcd3c0dd82b4c53f04194d1247c6eebdaab7c610cf7d66709b3b"
+
+	tests := []struct {
+		in                string
+		wantPath, wantRef string
+	}{
+		{in: "/mnt/images", wantPath: "/mnt/images"},
+		{in: "/mnt/images:sample", wantPath: "/mnt/images", wantRef: "sample"},
+		{in: "/mnt/images.tar:sample-amd64", wantPath: "/mnt/images.tar", wantRef: "sample-amd64"},
+		{in: "/mnt/images@" + dgst, wantPath: "/mnt/images", wantRef: dgst},
+		{in: "/mnt/a:b/images:v1", wantPath: "/mnt/a:b/images", wantRef: "v1"},
+		{in: "/mnt/a:b/images", wantPath: "/mnt/a:b/images"},
+		{in: "/mnt/a@b/images@" + dgst, wantPath: "/mnt/a@b/images", wantRef: dgst},
+		{in: `C:\images`, wantPath: `C:\images`},
+		{in: "images", wantPath: "images"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			path, ref := SplitImageLayoutReference(tt.in)
+			if path != tt.wantPath || ref != tt.wantRef {
+				t.Errorf("SplitImageLayoutReference(%q) = %q, %q, want %q, %q", tt.in, path, ref, tt.wantPath, tt.wantRef)
+			}
+		})
+	}
+}
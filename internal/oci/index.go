@@ -0,0 +1,152 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/registry/remote"
+)
+
+const (
+	// mediaTypeDockerManifestList is the docker equivalent of the OCI image index.
+	mediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+
+	// platformOS is the OS of gokrazy images.
+	platformOS = "linux"
+)
+
+// ErrNoMatchingPlatform denotes the error for an image index
+// without a manifest for the requested platform.
+var ErrNoMatchingPlatform = errors.New("no manifest matching the platform in the oci image index")
+
+// isIndex reports whether the manifest of the descriptor is an image index.
+func isIndex(desc ocispec.Descriptor, manifestContent []byte) bool {
+	switch desc.MediaType {
+	case ocispec.MediaTypeImageIndex, mediaTypeDockerManifestList:
+		return true
+	case ocispec.MediaTypeImageManifest:
+		return false
+	}
+
+	// Registries may not report the media type, fallback to the content one.
+	var m struct {
+		MediaType string `json:"mediaType"`
+	}
+	if err := json.Unmarshal(manifestContent, &m); err != nil {
+		return false
+	}
+
+	return m.MediaType == ocispec.MediaTypeImageIndex || m.MediaType == mediaTypeDockerManifestList
+}
+
+// platform returns the platform of gokrazy images for the architecture.
+func platform(arch string) *ocispec.Platform {
+	return &ocispec.Platform{OS: platformOS, Architecture: arch}
+}
+
+// matchesPlatform reports whether the platform of a manifest matches the architecture.
+func matchesPlatform(p *ocispec.Platform, arch string) bool {
+	return p != nil && p.Architecture == arch && (p.OS == "" || p.OS == platformOS)
+}
+
+func formatPlatform(p *ocispec.Platform) string {
+	if p == nil {
+		return "unknown"
+	}
+
+	return p.OS + "/" + p.Architecture
+}
+
+// selectManifest returns the descriptor of the manifest of the index for the architecture.
+// Without an architecture, the index must hold a single manifest.
+func selectManifest(index ocispec.Index, arch string) (ocispec.Descriptor, error) {
+	for i, m := range index.Manifests {
+		if err := validateDigest(fmt.Sprintf("manifests[%d].digest", i), m); err != nil {
+			return ocispec.Descriptor{}, err
+		}
+	}
+
+	if arch == "" && len(index.Manifests) == 1 {
+		return index.Manifests[0], nil
+	}
+
+	available := make([]string, 0, len(index.Manifests))
+	for _, m := range index.Manifests {
+		if arch != "" && matchesPlatform(m.Platform, arch) {
+			return m, nil
+		}
+
+		available = append(available, formatPlatform(m.Platform))
+	}
+
+	requested := "no architecture requested"
+	if arch != "" {
+		requested = "requested " + platformOS + "/" + arch
+	}
+
+	return ocispec.Descriptor{}, fmt.Errorf("%w: %s, available: %s",
+		ErrNoMatchingPlatform, requested, strings.Join(available, ", "))
+}
+
+// updateIndex tags an image index holding the manifest for its platform with the reference.
+// If the reference already points to an image index, its manifests
+// for the other platforms are kept and the one for the same platform is replaced.
+func updateIndex(ctx context.Context, repo *remote.Repository, reference string, manifest ocispec.Descriptor) (ocispec.Descriptor, error) {
+	manifests := []ocispec.Descriptor{manifest}
+
+	desc, rc, err := repo.FetchReference(ctx, reference)
+	switch {
+	case errors.Is(err, errdef.ErrNotFound):
+
+	case err != nil:
+		return ocispec.Descriptor{}, fmt.Errorf("error fetcing oci reference manifest: %w", err)
+
+	default:
+		existing, err := content.ReadAll(rc, desc)
+		rc.Close()
+
+		if err != nil {
+			return ocispec.Descriptor{}, fmt.Errorf("error reading oci reference manifest: %w", err)
+		}
+
+		if isIndex(desc, existing) {
+			var index ocispec.Index
+			if err := json.Unmarshal(existing, &index); err != nil {
+				return ocispec.Descriptor{}, fmt.Errorf("failed to json decode the oci image index: %w", err)
+			}
+
+			for _, m := range index.Manifests {
+				if !matchesPlatform(m.Platform, manifest.Platform.Architecture) {
+					manifests = append(manifests, m)
+				}
+			}
+		}
+	}
+
+	index := ocispec.Index{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: manifests,
+	}
+
+	indexContent, err := json.Marshal(index)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to json encode the oci image index: %w", err)
+	}
+
+	indexDescriptor := content.NewDescriptorFromBytes(ocispec.MediaTypeImageIndex, indexContent)
+
+	if err := repo.PushReference(ctx, indexDescriptor, bytes.NewReader(indexContent), reference); err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to push oci image index: %w", err)
+	}
+
+	return indexDescriptor, nil
+}
@@ -0,0 +1,161 @@
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func testPlatformManifest(arch string) ocispec.Descriptor {
+	desc := testDescriptor([]byte("manifest " + arch))
+	desc.MediaType = ocispec.MediaTypeImageManifest
+	if arch != "" {
+		desc.Platform = platform(arch)
+	}
+
+	return desc
+}
+
+func TestSelectManifest(t *testing.T) {
+	amd64, arm64 := testPlatformManifest("amd64"), testPlatformManifest("arm64")
+
+	windows := testPlatformManifest("arm64")
+	windows.Platform.OS = "windows"
+
+	noOS := testPlatformManifest("arm64")
+	noOS.Platform.OS = ""
+
+	badDigest := testPlatformManifest("amd64")
+	badDigest.Digest = "sha256:../../x"
+
+	tests := []struct {
+		name      string
+		manifests []ocispec.Descriptor
+		arch      string
+		want      digest.Digest
+		wantErr   error
+	}{
+		{name: "by arch", manifests: []ocispec.Descriptor{amd64, arm64}, arch: "arm64", want: arm64.Digest},
+		{name: "single manifest without arch", manifests: []ocispec.Descriptor{amd64}, want: amd64.Digest},
+		{name: "platform without os", manifests: []ocispec.Descriptor{amd64, noOS}, arch: "arm64", want: noOS.Digest},
+		{name: "other os", manifests: []ocispec.Descriptor{amd64, windows}, arch: "arm64", wantErr: ErrNoMatchingPlatform},
+		{name: "missing arch", manifests: []ocispec.Descriptor{amd64}, arch: "arm64", wantErr: ErrNoMatchingPlatform},
+		{name: "several manifests without arch", manifests: []ocispec.Descriptor{amd64, arm64}, wantErr: ErrNoMatchingPlatform},
+		{name: "empty index", arch: "amd64", wantErr: ErrNoMatchingPlatform},
+		{name: "invalid digest", manifests: []ocispec.Descriptor{badDigest, arm64}, arch: "arm64", wantErr: ErrInvalidManifest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := selectManifest(ocispec.Index{Manifests: tt.manifests}, tt.arch)
+			if !errors.Is(err, tt.wantErr) || got.Digest != tt.want {
+				t.Errorf("selectManifest() = %s, %v, want %s, %v", got.Digest, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUpdateIndex(t *testing.T) {
+	ctx := context.Background()
+	reg, repo := newTestRegistry(t, "v1")
+
+	index := func() []ocispec.Descriptor {
+		t.Helper()
+
+		b, mediaType := reg.manifest("v1")
+		if mediaType != ocispec.MediaTypeImageIndex {
+			t.Fatalf("v1 media type = %q, want %q", mediaType, ocispec.MediaTypeImageIndex)
+		}
+
+		var index ocispec.Index
+		if err := json.Unmarshal(b, &index); err != nil {
+			t.Fatal(err)
+		}
+
+		return index.Manifests
+	}
+
+	// A plain manifest is replaced.
+	reg.putManifest("v1", ocispec.MediaTypeImageManifest, []byte(`{"schemaVersion":2}`))
+
+	amd64 := testPlatformManifest("amd64")
+	if _, err := updateIndex(ctx, repo, "v1", amd64); err != nil {
+		t.Fatalf("updateIndex() = %v, want nil", err)
+	}
+
+	if got := index(); len(got) != 1 || got[0].Digest != amd64.Digest {
+		t.Fatalf("index manifests = %+v, want the amd64 one", got)
+	}
+
+	// The manifests of the other platforms are kept.
+	arm64 := testPlatformManifest("arm64")
+	if _, err := updateIndex(ctx, repo, "v1", arm64); err != nil {
+		t.Fatalf("updateIndex() = %v, want nil", err)
+	}
+
+	if got := index(); len(got) != 2 || got[0].Digest != arm64.Digest || got[1].Digest != amd64.Digest {
+		t.Fatalf("index manifests = %+v, want the arm64 and amd64 ones", got)
+	}
+
+	// The one of the same platform is replaced.
+	amd64v2 := testDescriptor([]byte("manifest amd64 v2"))
+	amd64v2.MediaType = ocispec.MediaTypeImageManifest
+	amd64v2.Platform = platform("amd64")
+
+	desc, err := updateIndex(ctx, repo, "v1", amd64v2)
+	if err != nil {
+		t.Fatalf("updateIndex() = %v, want nil", err)
+	}
+
+	got := index()
+	if len(got) != 2 || got[0].Digest != amd64v2.Digest || got[1].Digest != arm64.Digest {
+		t.Fatalf("index manifests = %+v, want the new amd64 and the arm64 ones", got)
+	}
+
+	if b, _ := reg.manifest("v1"); digest.FromBytes(b) != desc.Digest {
+		t.Errorf("updateIndex() = %s, want the digest of the pushed index %s", desc.Digest, digest.FromBytes(b))
+	}
+}
+
+func TestPushPullArch(t *testing.T) {
+	ctx := context.Background()
+	_, repo := newTestRegistry(t, "v1")
+	image := repo.Reference.String()
+
+	for _, arch := range []string{"amd64", "arm64"} {
+		gafPath := filepath.Join(t.TempDir(), LayerGaf)
+		if err := os.WriteFile(gafPath, []byte("gaf "+arch), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		opts := PushOptions{PlainHTTP: true, Arch: arch}
+		if _, err := Push(ctx, image, []Layer{{Title: LayerGaf, Path: gafPath}}, opts); err != nil {
+			t.Fatalf("Push(%s) = %v, want nil", arch, err)
+		}
+	}
+
+	for _, arch := range []string{"amd64", "arm64"} {
+		dir := t.TempDir()
+
+		layout, err := Pull(ctx, image, dir, PullOptions{PlainHTTP: true, Arch: arch})
+		if err != nil || layout != LayoutGaf {
+			t.Fatalf("Pull(%s) = %q, %v, want %q, nil", arch, layout, err, LayoutGaf)
+		}
+
+		if b, err := os.ReadFile(filepath.Join(dir, LayerGaf)); err != nil || string(b) != "gaf "+arch {
+			t.Errorf("pulled %s = %q, %v, want %q", LayerGaf, b, err, "gaf "+arch)
+		}
+	}
+
+	if _, err := Pull(ctx, image, t.TempDir(), PullOptions{PlainHTTP: true, Arch: "riscv64"}); !errors.Is(err, ErrNoMatchingPlatform) ||
+		!strings.Contains(err.Error(), "linux/amd64") {
+		t.Errorf("Pull(riscv64) = %v, want %v listing the available platforms", err, ErrNoMatchingPlatform)
+	}
+}
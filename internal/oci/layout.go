@@ -0,0 +1,180 @@
+package oci
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// Layout is the layout of the layers of a gom OCI artifact.
+type Layout string
+
+const (
+	// LayoutGaf is the layout of artifacts holding the image as a single .gaf layer,
+	// optionally along with its sbom.
+	LayoutGaf Layout = "gaf"
+
+	// LayoutParts is the layout of artifacts holding the image as mbr, boot and root layers,
+	// optionally along with its sbom.
+	LayoutParts Layout = "parts"
+)
+
+// The titles of the layers of LayoutParts artifacts.
+const (
+	LayerMBR  = "mbr.img"
+	LayerBoot = "boot.img"
+	LayerRoot = "root.img"
+)
+
+// ErrInvalidManifest denotes the error for an OCI manifest
+// that doesn't match the gom OCI artifact spec (docs/oci.md).
+var ErrInvalidManifest = errors.New("invalid gom oci manifest")
+
+// ManifestError is returned for an OCI manifest
+// that doesn't match the gom OCI artifact spec (docs/oci.md).
+type ManifestError struct {
+	// Field is the path of the wrong manifest field, e.g. layers[1].mediaType.
+	Field string
+
+	// Got is the value of the field.
+	Got string
+
+	// Want describes the values the field can have.
+	Want string
+}
+
+func (e *ManifestError) Error() string {
+	return fmt.Sprintf("%s: %s: got %q, want %s", ErrInvalidManifest, e.Field, e.Got, e.Want)
+}
+
+func (e *ManifestError) Is(target error) bool {
+	return target == ErrInvalidManifest //nolint:goerr113
+}
+
+// layouts maps the layouts to their required layer titles.
+var layouts = map[Layout][]string{
+	LayoutGaf:   {LayerGaf},
+	LayoutParts: {LayerMBR, LayerBoot, LayerRoot},
+}
+
+// ValidateManifest checks the manifest matches the gom OCI artifact spec
+// and returns the layout of its layers.
+func ValidateManifest(m ocispec.Manifest) (Layout, error) {
+	if m.SchemaVersion != 2 {
+		return "", &ManifestError{Field: "schemaVersion", Got: fmt.Sprint(m.SchemaVersion), Want: "2"}
+	}
+
+	if m.MediaType != "" && m.MediaType != ocispec.MediaTypeImageManifest {
+		return "", &ManifestError{Field: "mediaType", Got: m.MediaType, Want: quote(ocispec.MediaTypeImageManifest)}
+	}
+
+	if m.Config.MediaType != MediaTypeConfig {
+		return "", &ManifestError{Field: "config.mediaType", Got: m.Config.MediaType, Want: quote(MediaTypeConfig)}
+	}
+
+	if err := validateDigest("config.digest", m.Config); err != nil {
+		return "", err
+	}
+
+	titles := make(map[string]bool, len(m.Layers))
+	for i, layer := range m.Layers {
+		if layer.MediaType != ocispec.MediaTypeImageLayer {
+			return "", &ManifestError{
+				Field: fmt.Sprintf("layers[%d].mediaType", i),
+				Got:   layer.MediaType,
+				Want:  quote(ocispec.MediaTypeImageLayer),
+			}
+		}
+
+		if err := validateDigest(fmt.Sprintf("layers[%d].digest", i), layer); err != nil {
+			return "", err
+		}
+
+		title := layer.Annotations[ocispec.AnnotationTitle]
+		field := fmt.Sprintf("layers[%d].annotations[%s]", i, ocispec.AnnotationTitle)
+
+		switch {
+		case !isLayerTitle(title):
+			return "", &ManifestError{Field: field, Got: title, Want: "one of " + quote(allLayerTitles()...)}
+		case titles[title]:
+			return "", &ManifestError{Field: field, Got: title, Want: "a title not used by other layers"}
+		}
+
+		titles[title] = true
+	}
+
+	for _, layout := range []Layout{LayoutGaf, LayoutParts} {
+		if hasLayout(titles, layout) {
+			return layout, nil
+		}
+	}
+
+	got := make([]string, 0, len(titles))
+	for title := range titles {
+		got = append(got, title)
+	}
+	sort.Strings(got)
+
+	return "", &ManifestError{
+		Field: "layers",
+		Got:   strings.Join(got, ", "),
+		Want: fmt.Sprintf("either %s (and optionally %s) or %s (and optionally %s)",
+			quote(layouts[LayoutGaf]...), quote(LayerSBOM), quote(layouts[LayoutParts]...), quote(LayerSBOM)),
+	}
+}
+
+// validateDigest checks the digest of the descriptor is well formed,
+// as it is used for the blob paths in the cache.
+func validateDigest(field string, desc ocispec.Descriptor) error {
+	if err := desc.Digest.Validate(); err != nil {
+		return &ManifestError{Field: field, Got: string(desc.Digest), Want: "a valid digest"}
+	}
+
+	return nil
+}
+
+// hasLayout reports whether the layer titles are the ones of the layout.
+func hasLayout(titles map[string]bool, layout Layout) bool {
+	want := len(layouts[layout])
+	if titles[LayerSBOM] {
+		want++
+	}
+
+	if len(titles) != want {
+		return false
+	}
+
+	for _, title := range layouts[layout] {
+		if !titles[title] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isLayerTitle(title string) bool {
+	for _, t := range allLayerTitles() {
+		if title == t {
+			return true
+		}
+	}
+
+	return false
+}
+
+func allLayerTitles() []string {
+	return []string{LayerGaf, LayerMBR, LayerBoot, LayerRoot, LayerSBOM}
+}
+
+func quote(s ...string) string {
+	q := make([]string, len(s))
+	for i := range s {
+		q[i] = fmt.Sprintf("%q", s[i])
+	}
+
+	return strings.Join(q, ", ")
+}
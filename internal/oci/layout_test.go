@@ -0,0 +1,103 @@
+package oci
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func testLayer(title string) ocispec.Descriptor {
+	return ocispec.Descriptor{
+		MediaType:   ocispec.MediaTypeImageLayer,
+		Digest:      digest.FromString(title),
+		Annotations: map[string]string{ocispec.AnnotationTitle: title},
+	}
+}
+
+func testManifest(layers ...ocispec.Descriptor) ocispec.Manifest {
+	m := ocispec.Manifest{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config: ocispec.Descriptor{
+			MediaType: MediaTypeConfig,
+			Digest:    digest.FromString(""),
+		},
+		Layers: layers,
+	}
+	m.SchemaVersion = 2
+
+	return m
+}
+
+func TestValidateManifest(t *testing.T) {
+	badDigest := func(d digest.Digest) ocispec.Descriptor {
+		layer := testLayer(LayerGaf)
+		layer.Digest = d
+
+		return layer
+	}
+
+	badConfig := testManifest(testLayer(LayerGaf))
+	badConfig.Config.Digest = "sha256:../../x"
+
+	badMediaType := testLayer(LayerRoot)
+	badMediaType.MediaType = "application/octet-stream"
+
+	tests := []struct {
+		name      string
+		m         ocispec.Manifest
+		want      Layout
+		wantField string
+	}{
+		{name: "gaf", m: testManifest(testLayer(LayerGaf)), want: LayoutGaf},
+		{name: "gaf with sbom", m: testManifest(testLayer(LayerGaf), testLayer(LayerSBOM)), want: LayoutGaf},
+		{name: "parts", m: testManifest(testLayer(LayerMBR), testLayer(LayerBoot), testLayer(LayerRoot)), want: LayoutParts},
+		{
+			name: "parts with sbom",
+			m:    testManifest(testLayer(LayerSBOM), testLayer(LayerMBR), testLayer(LayerBoot), testLayer(LayerRoot)),
+			want: LayoutParts,
+		},
+		{name: "schema version", m: ocispec.Manifest{Config: testManifest().Config}, wantField: "schemaVersion"},
+		{name: "config digest", m: badConfig, wantField: "config.digest"},
+		{name: "layer digest without algorithm", m: testManifest(badDigest("nocolon")), wantField: "layers[0].digest"},
+		{name: "layer digest escaping the cache", m: testManifest(badDigest("sha256:../../x")), wantField: "layers[0].digest"},
+		{name: "layer media type", m: testManifest(testLayer(LayerMBR), badMediaType), wantField: "layers[1].mediaType"},
+		{
+			name:      "unknown title",
+			m:         testManifest(testLayer("disk.img")),
+			wantField: "layers[0].annotations[" + ocispec.AnnotationTitle + "]",
+		},
+		{
+			name:      "duplicate title",
+			m:         testManifest(testLayer(LayerGaf), testLayer(LayerGaf)),
+			wantField: "layers[1].annotations[" + ocispec.AnnotationTitle + "]",
+		},
+		{name: "missing part", m: testManifest(testLayer(LayerMBR), testLayer(LayerRoot)), wantField: "layers"},
+		{name: "mixed layouts", m: testManifest(testLayer(LayerGaf), testLayer(LayerMBR)), wantField: "layers"},
+		{name: "no layers", m: testManifest(), wantField: "layers"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ValidateManifest(tt.m)
+
+			if tt.wantField == "" {
+				if err != nil || got != tt.want {
+					t.Errorf("ValidateManifest() = %q, %v, want %q, nil", got, err, tt.want)
+				}
+
+				return
+			}
+
+			var merr *ManifestError
+			if !errors.As(err, &merr) || !errors.Is(err, ErrInvalidManifest) {
+				t.Fatalf("ValidateManifest() error = %v, want a ManifestError", err)
+			}
+
+			if merr.Field != tt.wantField {
+				t.Errorf("ValidateManifest() error field = %q, want %q", merr.Field, tt.wantField)
+			}
+		})
+	}
+}
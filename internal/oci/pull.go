@@ -1,93 +1,308 @@
 package oci
 
 import (
+	"bytes"
 	"context"
+	"crypto"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/fs"
 	"log"
 	"os"
 	"path"
-	"strings"
 
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"oras.land/oras-go/v2/content"
-	"oras.land/oras-go/v2/registry/remote"
-	"oras.land/oras-go/v2/registry/remote/auth"
+	"oras.land/oras-go/v2/errdef"
 )
 
 var blobFilePermission fs.FileMode = 0644
 
-// Pull pulls the OCI artifacts at the OCI Image reference and downloads them to the specified dir.
-func Pull(ctx context.Context, image string, username string, password string, outputDir string, plainHTTP bool) error {
-	// Parse image into chunks.
-	chunks := strings.Split(image, "/")
-	subChunks := strings.Split(chunks[2], ":")
+var (
+	// ErrOfflineNoCache denotes the error for an offline pull without a cache.
+	ErrOfflineNoCache = errors.New("offline pull requires a cache")
 
-	registryName := chunks[0]
-	repositoryName := chunks[1] + "/" + subChunks[0]
-	imageReference := subChunks[1]
+	// ErrMissingSignatureKey denotes the error for a pull requiring signatures without a public key.
+	ErrMissingSignatureKey = errors.New("signature verification requires a public key")
+)
+
+// source is a store OCI artifacts are pulled from.
+type source interface {
+	content.Fetcher
+
+	// Resolve returns the descriptor of the manifest with the reference.
+	Resolve(ctx context.Context, reference string) (ocispec.Descriptor, error)
+}
+
+// PullOptions configures Pull.
+type PullOptions struct {
+	// Username and Password are the OCI registry credentials,
+	// if both are empty the DefaultCredential is used.
+	Username string
+	Password string
+
+	// PlainHTTP allows the use of plain HTTP for the OCI registry.
+	PlainHTTP bool
+
+	// Cache, if set, is consulted before downloading blobs
+	// and stores the downloaded ones.
+	Cache *Cache
+
+	// Offline resolves the reference and its blobs from Cache only,
+	// without contacting the registry.
+	Offline bool
+
+	// Progress, if set, is called with the progress of the blob downloads.
+	Progress ProgressFunc
+
+	// Concurrency is the maximum number of blobs downloaded at once,
+	// DefaultConcurrency if zero.
+	Concurrency int
+
+	// Retries is the number of times a failed blob download is retried,
+	// resuming it where it stopped if the registry supports range requests.
+	// DefaultRetries if zero, no retries if negative.
+	Retries int
+
+	// Arch is the architecture of the manifest to pull when the reference
+	// resolves to an image index. It can be empty if the index holds a single manifest.
+	Arch string
+
+	// SignatureKey, if set, is the public key the cosign signatures of the artifact
+	// are verified with: pulling fails if the artifact has signatures and none is verified.
+	SignatureKey crypto.PublicKey
+
+	// RequireSignature makes pulling fail if the artifact has no signatures.
+	RequireSignature bool
+}
+
+// Pull pulls the OCI artifacts at the OCI Image reference and downloads them to the specified dir,
+// each layer to a file named after its title. It returns the layout of the layers,
+// or a *ManifestError if the manifest doesn't match the gom OCI artifact spec.
+func Pull(ctx context.Context, image string, outputDir string, opts PullOptions) (Layout, error) {
+	ref, err := ParseReference(image)
+	if err != nil {
+		return "", err
+	}
+
+	if opts.Offline && opts.Cache == nil {
+		return "", ErrOfflineNoCache
+	}
+
+	var src source
+	if !opts.Offline {
+		src = newRepository(ref, opts.Username, opts.Password, opts.PlainHTTP)
+
+		log.Printf("pulling blobs from %s\n", ref)
+	} else {
+		log.Printf("pulling blobs from %s (offline)\n", ref)
+	}
+
+	return pull(ctx, src, ref.Registry+"/"+ref.Repository, ref.Reference, outputDir, opts)
+}
+
+// pull pulls the OCI artifacts with the reference from src, the repository repoName, to the specified dir.
+// With a nil src the reference is resolved from the cache only.
+func pull(ctx context.Context, src source, repoName, reference, outputDir string, opts PullOptions) (Layout, error) {
+	if opts.RequireSignature && opts.SignatureKey == nil {
+		return "", ErrMissingSignatureKey
+	}
+
+	// Check if the Output Path exists before writing to it.
+	if _, err := os.Stat(outputDir); os.IsNotExist(err) {
+		return "", fmt.Errorf("specified output path '%s' does not exits: %w", outputDir, err)
+	}
+
+	manifestDescriptor, pulledContent, err := fetchManifest(ctx, src, cacheKey(repoName, reference), reference, opts.Cache)
+	if err != nil {
+		return "", err
+	}
+
+	// The signatures can be of the reference manifest, or of the platform one for image indexes.
+	signed := []ocispec.Descriptor{manifestDescriptor}
+
+	if isIndex(manifestDescriptor, pulledContent) {
+		var index ocispec.Index
+		if err := json.Unmarshal(pulledContent, &index); err != nil {
+			return "", fmt.Errorf("failed to json decode the pulled oci image index: %w", err)
+		}
+
+		manifestDescriptor, err = selectManifest(index, opts.Arch)
+		if err != nil {
+			return "", err
+		}
+
+		log.Printf("using manifest %s for %s\n", manifestDescriptor.Digest, formatPlatform(manifestDescriptor.Platform))
+
+		pulledContent, err = fetchContent(ctx, src, manifestDescriptor, opts.Cache)
+		if err != nil {
+			return "", err
+		}
+
+		signed = append(signed, manifestDescriptor)
+	}
+
+	if opts.SignatureKey != nil {
+		err := verifySignatures(ctx, src, repoName, opts.Cache, opts.SignatureKey, signed...)
+		switch {
+		case errors.Is(err, ErrUnsigned) && !opts.RequireSignature:
+			log.Printf("warning: %s, skipping signature verification\n", err)
+		case err != nil:
+			return "", err
+		}
+	}
+
+	// JSON Decodes the bytes read into an OCI Manifest.
+	var pulledManifest ocispec.Manifest
+	if err := json.Unmarshal(pulledContent, &pulledManifest); err != nil {
+		return "", fmt.Errorf("failed to json decode the pulled oci manifest: %w", err)
+	}
 
-	repo, err := remote.NewRepository(fmt.Sprintf("%s/%s", registryName, repositoryName))
+	layout, err := ValidateManifest(pulledManifest)
 	if err != nil {
-		return fmt.Errorf("error setting up repository: %w", err)
+		return "", err
 	}
 
-	// Setup the client credentials.
-	repo.Client = &auth.Client{
-		Credential: func(_ context.Context, _ string) (auth.Credential, error) {
-			return auth.Credential{
-				Username: username,
-				Password: password,
-			}, nil
-		},
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
 	}
 
-	if plainHTTP {
-		repo.PlainHTTP = true
+	// Download the content (blob) of the layers found in the OCI Manifest.
+	err = forEachConcurrently(ctx, pulledManifest.Layers, concurrency, func(ctx context.Context, layer ocispec.Descriptor) error {
+		filename := layer.Annotations[ocispec.AnnotationTitle]
+		dest := path.Join(outputDir, filename)
+
+		return fetchBlob(ctx, src, layer, filename, dest, opts)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return layout, nil
+}
+
+// fetchManifest returns the descriptor and content of the manifest the reference resolves to.
+// With a nil src the reference is resolved from the cache only.
+func fetchManifest(ctx context.Context, src source, name, reference string, cache *Cache) (ocispec.Descriptor, []byte, error) {
+	if src == nil {
+		desc, err := cache.Ref(name)
+		if err != nil {
+			return ocispec.Descriptor{}, nil, err
+		}
+
+		pulledContent, err := cache.ReadAll(desc)
+
+		return desc, pulledContent, err
 	}
 
-	log.Printf("pulling blobs from %s/%s:%s\n", registryName, repositoryName, imageReference)
+	// Obtains the manifest descriptor for the specified reference (tag or digest).
+	manifestDescriptor, err := src.Resolve(ctx, reference)
+	if err != nil {
+		return ocispec.Descriptor{}, nil, fmt.Errorf("error resolving oci reference manifest: %w", err)
+	}
 
-	// Obtains the manifest descriptor for the specified imageReference.
-	manifestDescriptor, rc, err := repo.FetchReference(ctx, imageReference)
+	rc, err := src.Fetch(ctx, manifestDescriptor)
 	if err != nil {
-		return fmt.Errorf("error fetcing oci reference manifest: %w", err)
+		return ocispec.Descriptor{}, nil, fmt.Errorf("error fetcing oci reference manifest: %w", err)
 	}
 	defer rc.Close()
 
 	// Read the bytes of the manifest descriptor from the io.ReadCloser.
 	pulledContent, err := content.ReadAll(rc, manifestDescriptor)
 	if err != nil {
-		return fmt.Errorf("error reading oci reference manifest: %w", err)
+		return ocispec.Descriptor{}, nil, fmt.Errorf("error reading oci reference manifest: %w", err)
 	}
 
-	// JSON Decodes the bytes read into an OCI Manifest.
-	var pulledManifest ocispec.Manifest
-	if err := json.Unmarshal(pulledContent, &pulledManifest); err != nil {
-		return fmt.Errorf("failed to json decode the pulled oci manifest: %w", err)
+	if cache != nil {
+		if err := cache.Put(manifestDescriptor, bytes.NewReader(pulledContent)); err != nil {
+			return ocispec.Descriptor{}, nil, err
+		}
+
+		if err := cache.SetRef(name, manifestDescriptor); err != nil {
+			return ocispec.Descriptor{}, nil, err
+		}
 	}
 
-	// Check if the Output Path exists before writing to it.
-	if _, err := os.Stat(outputDir); os.IsNotExist(err) {
-		return fmt.Errorf("specified output path '%s' does not exits: %w", outputDir, err)
+	return manifestDescriptor, pulledContent, nil
+}
+
+// isNotFound reports whether the error is about content missing from a source or the cache.
+func isNotFound(err error) bool {
+	return errors.Is(err, errdef.ErrNotFound) || errors.Is(err, ErrNotCached) || errors.Is(err, ErrNotInImageLayout)
+}
+
+// fetchContent returns the content of the manifest (or small blob) of the descriptor,
+// from the cache when it has it. With a nil src it is read from the cache only.
+func fetchContent(ctx context.Context, src source, desc ocispec.Descriptor, cache *Cache) ([]byte, error) {
+	if cache != nil && cache.Has(desc) {
+		return cache.ReadAll(desc)
 	}
 
-	// Loop over the layers found in the OCI Manifest to download their content (blob).
-	for _, layer := range pulledManifest.Layers {
-		filename := layer.Annotations["org.opencontainers.image.title"]
+	if src == nil {
+		return nil, fmt.Errorf("%w: manifest %s", ErrNotCached, desc.Digest)
+	}
 
-		log.Printf("downloading blob %s [%s]\n", filename, byteCountIEC(layer.Size))
+	pulledContent, err := content.FetchAll(ctx, src, desc)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching oci manifest %s: %w", desc.Digest, err)
+	}
+
+	if cache != nil {
+		if err := cache.Put(desc, bytes.NewReader(pulledContent)); err != nil {
+			return nil, err
+		}
+	}
+
+	return pulledContent, nil
+}
+
+// fetchBlob streams the content of the layer to dest, from the cache when it has it.
+// With a nil src the layer is read from the cache only.
+func fetchBlob(ctx context.Context, src source, layer ocispec.Descriptor, filename, dest string, opts PullOptions) error {
+	if opts.Cache != nil && opts.Cache.Has(layer) {
+		log.Printf("using cached blob %s [%s]\n", filename, byteCountIEC(layer.Size))
+
+		err := opts.Cache.Link(layer, dest)
+		if src == nil || !errors.Is(err, ErrDigestMismatch) {
+			return err
+		}
 
-		pulledBlob, err := content.FetchAll(ctx, repo, layer)
+		log.Printf("corrupt cached blob %s, downloading it again\n", filename)
+	}
+
+	if src == nil {
+		return fmt.Errorf("%w: blob %s (%s)", ErrNotCached, filename, layer.Digest)
+	}
+
+	log.Printf("downloading blob %s [%s]\n", filename, byteCountIEC(layer.Size))
+
+	retries := opts.Retries
+	switch {
+	case retries == 0:
+		retries = DefaultRetries
+	case retries < 0:
+		retries = 0
+	}
+
+	if opts.Cache != nil {
+		p, err := opts.Cache.blobFile(layer)
 		if err != nil {
-			return fmt.Errorf("failed to fetch layer content (blob): %w", err)
+			return err
 		}
 
-		dest := path.Join(outputDir, filename)
-		if err := os.WriteFile(dest, pulledBlob, blobFilePermission); err != nil {
-			return fmt.Errorf("failed to write layer content (blob) to file %s: %w", dest, err)
+		if err := download(ctx, src, layer, filename, p, retries, opts.Progress); err != nil {
+			return fmt.Errorf("failed to download layer content (blob) %s to cache: %w", filename, err)
 		}
+
+		opts.Cache.markVerified(layer.Digest)
+
+		return opts.Cache.Link(layer, dest)
+	}
+
+	if err := download(ctx, src, layer, filename, dest, retries, opts.Progress); err != nil {
+		return fmt.Errorf("failed to download layer content (blob) %s: %w", filename, err)
 	}
 
 	return nil
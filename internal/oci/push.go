@@ -0,0 +1,194 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/registry/remote"
+)
+
+// MediaTypeConfig is the media type of the empty config of gom OCI artifacts.
+const MediaTypeConfig = "application/vnd.unknown.config.v1+json"
+
+// The titles of the layers of gom OCI artifacts.
+const (
+	LayerGaf  = "disk.gaf"
+	LayerSBOM = "sbom.json"
+)
+
+// ErrPushDigestReference denotes the error for a push to a digest reference.
+var ErrPushDigestReference = errors.New("unable to push to a digest reference, please use a tag")
+
+// PushOptions configures Push.
+type PushOptions struct {
+	// Username and Password are the OCI registry credentials,
+	// if both are empty the DefaultCredential is used.
+	Username string
+	Password string
+
+	// PlainHTTP allows the use of plain HTTP for the OCI registry.
+	PlainHTTP bool
+
+	// Progress, if set, is called with the progress of the blob uploads.
+	Progress ProgressFunc
+
+	// Arch, if set, is the architecture of the pushed image: the reference is then
+	// tagged with an image index holding its manifest along with the ones
+	// already pushed to the reference for the other architectures.
+	Arch string
+}
+
+// Layer is a file to push as a layer of an OCI artifact.
+type Layer struct {
+	// Title is the org.opencontainers.image.title annotation of the layer,
+	// the file name it is pulled to.
+	Title string
+
+	// Path is the path of the file holding the layer content.
+	Path string
+}
+
+// Push pushes the layers of an OCI artifact at the OCI Image reference,
+// laid out as described in docs/oci.md, and returns the descriptor of the manifest
+// (or image index, if PushOptions.Arch is set) the reference is tagged with.
+func Push(ctx context.Context, image string, layers []Layer, opts PushOptions) (ocispec.Descriptor, error) {
+	ref, err := ParseReference(image)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	if ref.ValidateReferenceAsDigest() == nil {
+		return ocispec.Descriptor{}, fmt.Errorf("%w: %s", ErrPushDigestReference, ref)
+	}
+
+	repo := newRepository(ref, opts.Username, opts.Password, opts.PlainHTTP)
+
+	log.Printf("pushing blobs to %s\n", ref)
+
+	config := content.NewDescriptorFromBytes(MediaTypeConfig, []byte{})
+	if err := pushBytes(ctx, repo, config, []byte{}); err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to push config: %w", err)
+	}
+
+	descriptors := make([]ocispec.Descriptor, 0, len(layers))
+	for _, layer := range layers {
+		desc, err := pushFile(ctx, repo, layer, opts.Progress)
+		if err != nil {
+			return ocispec.Descriptor{}, err
+		}
+
+		descriptors = append(descriptors, desc)
+	}
+
+	manifest := ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    config,
+		Layers:    descriptors,
+		Annotations: map[string]string{
+			ocispec.AnnotationCreated: time.Now().UTC().Format(time.RFC3339),
+		},
+	}
+
+	manifestContent, err := json.Marshal(manifest)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to json encode the oci manifest: %w", err)
+	}
+
+	manifestDescriptor := content.NewDescriptorFromBytes(ocispec.MediaTypeImageManifest, manifestContent)
+
+	if opts.Arch == "" {
+		if err := repo.PushReference(ctx, manifestDescriptor, bytes.NewReader(manifestContent), ref.Reference); err != nil {
+			return ocispec.Descriptor{}, fmt.Errorf("failed to push oci manifest: %w", err)
+		}
+
+		return manifestDescriptor, nil
+	}
+
+	if err := repo.Push(ctx, manifestDescriptor, bytes.NewReader(manifestContent)); err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to push oci manifest: %w", err)
+	}
+
+	manifestDescriptor.Platform = platform(opts.Arch)
+
+	return updateIndex(ctx, repo, ref.Reference, manifestDescriptor)
+}
+
+// pushBytes pushes the blob of the descriptor, unless the repository already has it.
+func pushBytes(ctx context.Context, repo *remote.Repository, desc ocispec.Descriptor, b []byte) error {
+	exists, err := repo.Exists(ctx, desc)
+	if err != nil {
+		return fmt.Errorf("failed to check blob %s existence: %w", desc.Digest, err)
+	}
+
+	if exists {
+		return nil
+	}
+
+	return repo.Push(ctx, desc, bytes.NewReader(b))
+}
+
+// pushFile streams the file of the layer as a blob, unless the repository already has it,
+// and returns its descriptor.
+func pushFile(ctx context.Context, repo *remote.Repository, l Layer, progress ProgressFunc) (ocispec.Descriptor, error) {
+	file, filename := l.Path, l.Title
+
+	f, err := os.Open(file)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to open layer file %s: %w", file, err)
+	}
+	defer f.Close()
+
+	dgst, err := digest.FromReader(f)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to compute digest of layer file %s: %w", file, err)
+	}
+
+	size, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to get size of layer file %s: %w", file, err)
+	}
+
+	layer := ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageLayer,
+		Digest:    dgst,
+		Size:      size,
+		Annotations: map[string]string{
+			ocispec.AnnotationTitle: filename,
+		},
+	}
+
+	exists, err := repo.Exists(ctx, layer)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to check blob %s existence: %w", dgst, err)
+	}
+
+	if exists {
+		log.Printf("blob %s [%s] already exists\n", filename, byteCountIEC(size))
+
+		return layer, nil
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to rewind layer file %s: %w", file, err)
+	}
+
+	log.Printf("uploading blob %s [%s]\n", filename, byteCountIEC(size))
+
+	if err := repo.Push(ctx, layer, newProgressReader(f, filename, 0, size, progress)); err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to push layer content (blob) %s: %w", filename, err)
+	}
+
+	return layer, nil
+}
@@ -0,0 +1,55 @@
+package oci
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"oras.land/oras-go/v2/registry"
+)
+
+const (
+	defaultRegistry   = "docker.io"
+	defaultRepository = "library"
+	defaultTag        = "latest"
+)
+
+// ErrInvalidReference denotes the error for an OCI reference that can't be parsed.
+var ErrInvalidReference = errors.New("invalid oci reference")
+
+// ParseReference parses an OCI reference of the form [registry/]repository[:tag][@digest].
+// Like docker does, references without a registry default to docker.io
+// (and to the library namespace when they have a single path component),
+// references without a tag nor a digest default to the latest tag.
+func ParseReference(image string) (registry.Reference, error) {
+	if image == "" {
+		return registry.Reference{}, fmt.Errorf("%w: empty reference", ErrInvalidReference)
+	}
+
+	name := image
+	first, rest, found := strings.Cut(image, "/")
+
+	switch {
+	case !found:
+		name = defaultRegistry + "/" + defaultRepository + "/" + image
+	case !strings.ContainsAny(first, ".:") && first != "localhost":
+		name = defaultRegistry + "/" + image
+	case rest == "":
+		return registry.Reference{}, fmt.Errorf("%w: %q has no repository", ErrInvalidReference, image)
+	}
+
+	ref, err := registry.ParseReference(name)
+	if err != nil {
+		return registry.Reference{}, fmt.Errorf("%w: %q: %w", ErrInvalidReference, image, err)
+	}
+
+	if ref.Reference == "" {
+		if strings.HasSuffix(image, ":") || strings.HasSuffix(image, "@") {
+			return registry.Reference{}, fmt.Errorf("%w: %q has an empty tag or digest", ErrInvalidReference, image)
+		}
+
+		ref.Reference = defaultTag
+	}
+
+	return ref, nil
+}
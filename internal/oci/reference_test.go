@@ -0,0 +1,54 @@
+package oci
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseReference(t *testing.T) {
+	const dgst = "sha256:6c3c624b58dbbcd3c0dd82b4c53f04194d1247c6eebdaab7c610cf7d66709b3b"
+
+	tests := []struct {
+		in             string
+		wantRegistry   string
+		wantRepository string
+		wantReference  string
+		wantErr        error
+	}{
+		{in: "gokrazy", wantRegistry: "docker.io", wantRepository: "library/gokrazy", wantReference: "latest"},
+		{in: "gokrazy:v1", wantRegistry: "docker.io", wantRepository: "library/gokrazy", wantReference: "v1"},
+		{in: "damdo/gokrazy:v1", wantRegistry: "docker.io", wantRepository: "damdo/gokrazy", wantReference: "v1"},
+		{in: "docker.io/damdo/gokrazy", wantRegistry: "docker.io", wantRepository: "damdo/gokrazy", wantReference: "latest"},
+		{in: "localhost/gokrazy:v1", wantRegistry: "localhost", wantRepository: "gokrazy", wantReference: "v1"},
+		{in: "localhost:5000/gokrazy", wantRegistry: "localhost:5000", wantRepository: "gokrazy", wantReference: "latest"},
+		{in: "localhost:5000/foo:tag", wantRegistry: "localhost:5000", wantRepository: "foo", wantReference: "tag"},
+		{in: "ghcr.io/org/team/img:tag", wantRegistry: "ghcr.io", wantRepository: "org/team/img", wantReference: "tag"},
+		{in: "ghcr.io/org/img@" + dgst, wantRegistry: "ghcr.io", wantRepository: "org/img", wantReference: dgst},
+		{in: "gokrazy@" + dgst, wantRegistry: "docker.io", wantRepository: "library/gokrazy", wantReference: dgst},
+		{in: "127.0.0.1:5000/org/img:v1.2.3", wantRegistry: "127.0.0.1:5000", wantRepository: "org/img", wantReference: "v1.2.3"},
+		{in: "", wantErr: ErrInvalidReference},
+		{in: "ghcr.io/", wantErr: ErrInvalidReference},
+		{in: "ghcr.io/Org/img", wantErr: ErrInvalidReference},
+		{in: "ghcr.io/org/img:", wantErr: ErrInvalidReference},
+		{in: "ghcr.io/org/img@", wantErr: ErrInvalidReference},
+		{in: "ghcr.io/org/img@sha256:nothex", wantErr: ErrInvalidReference},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseReference(tt.in)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseReference(%q) error = %v, want %v", tt.in, err, tt.wantErr)
+			}
+
+			if tt.wantErr != nil {
+				return
+			}
+
+			if got.Registry != tt.wantRegistry || got.Repository != tt.wantRepository || got.Reference != tt.wantReference {
+				t.Errorf("ParseReference(%q) = %s, %s, %s, want %s, %s, %s", tt.in,
+					got.Registry, got.Repository, got.Reference, tt.wantRegistry, tt.wantRepository, tt.wantReference)
+			}
+		})
+	}
+}
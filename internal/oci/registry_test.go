@@ -0,0 +1,146 @@
+package oci
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	"oras.land/oras-go/v2/registry"
+	"oras.land/oras-go/v2/registry/remote"
+)
+
+// testRegistry is an in memory OCI distribution registry, for a single repository.
+type testRegistry struct {
+	mu        sync.Mutex
+	blobs     map[string][]byte
+	manifests map[string][]byte
+	types     map[string]string
+}
+
+// newTestRegistry starts a testRegistry and returns the repository with the name of the reference on it.
+func newTestRegistry(t *testing.T, reference string) (*testRegistry, *remote.Repository) {
+	t.Helper()
+
+	r := &testRegistry{blobs: map[string][]byte{}, manifests: map[string][]byte{}, types: map[string]string{}}
+
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+
+	ref, err := registry.ParseReference(strings.TrimPrefix(srv.URL, "http://") + "/gokrazy/hello:" + reference)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return r, newRepository(ref, "", "", true)
+}
+
+// manifest returns the manifest with the reference, a tag or a digest, and its media type.
+func (r *testRegistry) manifest(reference string) ([]byte, string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.manifests[reference], r.types[reference]
+}
+
+// putManifest stores the manifest with the tag, if any, and its digest.
+func (r *testRegistry) putManifest(tag, mediaType string, b []byte) digest.Digest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	d := digest.FromBytes(b)
+	for _, ref := range []string{tag, d.String()} {
+		if ref != "" {
+			r.manifests[ref] = b
+			r.types[ref] = mediaType
+		}
+	}
+
+	return d
+}
+
+// putBlob stores the blob.
+func (r *testRegistry) putBlob(b []byte) digest.Digest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	d := digest.FromBytes(b)
+	r.blobs[d.String()] = b
+
+	return d
+}
+
+func (r *testRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	p := req.URL.Path
+	last := p[strings.LastIndex(p, "/")+1:]
+
+	switch {
+	case p == "/v2/":
+
+	case strings.Contains(p, "/blobs/uploads/") && req.Method == http.MethodPost:
+		w.Header().Set("Location", p+"upload")
+		w.WriteHeader(http.StatusAccepted)
+
+	case strings.Contains(p, "/blobs/uploads/") && req.Method == http.MethodPut:
+		b, _ := io.ReadAll(req.Body)
+
+		d := req.URL.Query().Get("digest")
+		if digest.FromBytes(b).String() != d {
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		r.mu.Lock()
+		r.blobs[d] = b
+		r.mu.Unlock()
+
+		w.WriteHeader(http.StatusCreated)
+
+	case strings.Contains(p, "/blobs/"):
+		r.mu.Lock()
+		b, ok := r.blobs[last]
+		r.mu.Unlock()
+
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+
+			return
+		}
+
+		w.Header().Set("Docker-Content-Digest", last)
+		http.ServeContent(w, req, "", time.Time{}, bytes.NewReader(b))
+
+	case strings.Contains(p, "/manifests/") && req.Method == http.MethodPut:
+		b, _ := io.ReadAll(req.Body)
+		d := r.putManifest(last, req.Header.Get("Content-Type"), b)
+
+		w.Header().Set("Docker-Content-Digest", d.String())
+		w.WriteHeader(http.StatusCreated)
+
+	case strings.Contains(p, "/manifests/"):
+		b, mediaType := r.manifest(last)
+		if b == nil {
+			w.WriteHeader(http.StatusNotFound)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", mediaType)
+		w.Header().Set("Docker-Content-Digest", digest.FromBytes(b).String())
+		w.Header().Set("Content-Length", strconv.Itoa(len(b)))
+
+		if req.Method != http.MethodHead {
+			_, _ = w.Write(b)
+		}
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
@@ -0,0 +1,24 @@
+package oci
+
+import (
+	"oras.land/oras-go/v2/registry"
+	"oras.land/oras-go/v2/registry/remote"
+	"oras.land/oras-go/v2/registry/remote/auth"
+)
+
+// newRepository returns the remote repository of the reference,
+// authenticating with the given credentials if any, or with the DefaultCredential otherwise.
+func newRepository(ref registry.Reference, username, password string, plainHTTP bool) *remote.Repository {
+	repo := &remote.Repository{Reference: ref}
+
+	// Setup the client credentials.
+	repo.Client = &auth.Client{
+		Credential: credentialFunc(username, password),
+	}
+
+	if plainHTTP {
+		repo.PlainHTTP = true
+	}
+
+	return repo
+}
@@ -0,0 +1,260 @@
+package oci
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/registry"
+)
+
+const (
+	// mediaTypeSimpleSigning is the media type of cosign signature payloads.
+	mediaTypeSimpleSigning = "application/vnd.dev.cosign.simplesigning.v1+json"
+
+	// artifactTypeCosignSignature is the artifact type of cosign signatures
+	// attached with the referrers API.
+	artifactTypeCosignSignature = "application/vnd.dev.cosign.artifact.sig.v1+json"
+
+	// annotationCosignSignature is the annotation of the base64 encoded signature of a payload.
+	annotationCosignSignature = "dev.cosignproject.cosign/signature"
+
+	// cosignSignatureType is the type of cosign container image signature payloads.
+	cosignSignatureType = "cosign container image signature"
+
+	// cosignSignatureTagSuffix is the suffix of the tags cosign attaches signatures with.
+	cosignSignatureTagSuffix = ".sig"
+
+	pemPublicKey = "PUBLIC KEY"
+)
+
+var (
+	// ErrUnsigned denotes the error for an OCI artifact without signatures.
+	ErrUnsigned = errors.New("oci artifact is not signed")
+
+	// ErrInvalidSignature denotes the error for an OCI artifact without a signature
+	// verified by the public key.
+	ErrInvalidSignature = errors.New("oci artifact signature verification failed")
+
+	// ErrUnsupportedKey denotes the error for a public key of an unsupported type.
+	ErrUnsupportedKey = errors.New("unsupported public key, expected an ECDSA or ed25519 PEM public key")
+)
+
+// simpleSigning is the cosign signature payload.
+type simpleSigning struct {
+	Critical struct {
+		Identity struct {
+			DockerReference string `json:"docker-reference"`
+		} `json:"identity"`
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+}
+
+// LoadPublicKey loads the PEM encoded ECDSA or ed25519 public key at path,
+// as generated by cosign generate-key-pair or openssl.
+func LoadPublicKey(path string) (crypto.PublicKey, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading public key %s: %w", path, err)
+	}
+
+	block, _ := pem.Decode(b)
+	if block == nil || block.Type != pemPublicKey {
+		return nil, fmt.Errorf("%w: %s has no %s PEM block", ErrUnsupportedKey, path, pemPublicKey)
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrUnsupportedKey, path, err)
+	}
+
+	switch key.(type) {
+	case *ecdsa.PublicKey, ed25519.PublicKey:
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s is a %T", ErrUnsupportedKey, path, key)
+}
+
+// verifySignature checks the signature of the payload with the key,
+// the way cosign signs payloads: ECDSA over its SHA256, ed25519 over the payload itself.
+func verifySignature(key crypto.PublicKey, payload, sig []byte) bool {
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		sum := sha256.Sum256(payload)
+
+		return ecdsa.VerifyASN1(k, sum[:], sig)
+	case ed25519.PublicKey:
+		return ed25519.Verify(k, payload, sig)
+	}
+
+	return false
+}
+
+// signatureTag returns the tag cosign attaches the signatures of the digest with.
+func signatureTag(dgst digest.Digest) string {
+	return dgst.Algorithm().String() + "-" + dgst.Encoded() + cosignSignatureTagSuffix
+}
+
+// verifySignatures checks at least one of the cosign signatures of the manifests
+// is verified by the key. The signatures are discovered with the cosign tag schema
+// and, if src supports it, the referrers API.
+// It returns ErrUnsigned if there are no signatures and ErrInvalidSignature if none is verified.
+func verifySignatures(ctx context.Context, src source, repoName string, cache *Cache,
+	key crypto.PublicKey, manifests ...ocispec.Descriptor,
+) error {
+	found := 0
+
+	for _, m := range manifests {
+		layers, err := signatureLayers(ctx, src, repoName, cache, m)
+		if err != nil {
+			return err
+		}
+
+		for _, layer := range layers {
+			found++
+
+			payload, err := fetchContent(ctx, src, layer, cache)
+			if err != nil {
+				return fmt.Errorf("error fetching signature payload %s: %w", layer.Digest, err)
+			}
+
+			if verifyLayer(key, layer, payload, m.Digest) {
+				log.Printf("verified signature %s of %s\n", layer.Digest, m.Digest)
+
+				return nil
+			}
+		}
+	}
+
+	if found == 0 {
+		return ErrUnsigned
+	}
+
+	return fmt.Errorf("%w: none of the %d signatures is verified by the public key", ErrInvalidSignature, found)
+}
+
+// verifyLayer checks the cosign signature layer is a signature of dgst verified by the key.
+func verifyLayer(key crypto.PublicKey, layer ocispec.Descriptor, payload []byte, dgst digest.Digest) bool {
+	sig, err := base64.StdEncoding.DecodeString(layer.Annotations[annotationCosignSignature])
+	if err != nil || !verifySignature(key, payload, sig) {
+		return false
+	}
+
+	var s simpleSigning
+	if err := json.Unmarshal(payload, &s); err != nil {
+		return false
+	}
+
+	return s.Critical.Type == cosignSignatureType && s.Critical.Image.DockerManifestDigest == dgst.String()
+}
+
+// signatureLayers returns the cosign signature layers of the manifest.
+func signatureLayers(ctx context.Context, src source, repoName string, cache *Cache, m ocispec.Descriptor) ([]ocispec.Descriptor, error) {
+	var sigManifests []ocispec.Descriptor
+
+	tag := signatureTag(m.Digest)
+
+	desc, sigContent, err := fetchManifest(ctx, src, cacheKey(repoName, tag), tag, cache)
+	switch {
+	case err == nil:
+		layers, err := simpleSigningLayers(sigContent)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Printf("found signature manifest %s of %s\n", desc.Digest, m.Digest)
+
+		sigManifests = append(sigManifests, layers...)
+
+	case !isNotFound(err):
+		return nil, fmt.Errorf("error fetching signatures of %s: %w", m.Digest, err)
+	}
+
+	rf, ok := src.(registry.ReferrerFinder)
+	if !ok {
+		return sigManifests, nil
+	}
+
+	var referrers []ocispec.Descriptor
+	if err := rf.Referrers(ctx, m, artifactTypeCosignSignature, func(page []ocispec.Descriptor) error {
+		referrers = append(referrers, page...)
+
+		return nil
+	}); err != nil && !isNotFound(err) {
+		return nil, fmt.Errorf("error listing referrers of %s: %w", m.Digest, err)
+	}
+
+	for i, r := range referrers {
+		if err := validateDigest(fmt.Sprintf("referrers[%d].digest", i), r); err != nil {
+			return nil, err
+		}
+
+		sigContent, err := fetchContent(ctx, src, r, cache)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching signature manifest %s: %w", r.Digest, err)
+		}
+
+		layers, err := simpleSigningLayers(sigContent)
+		if err != nil {
+			return nil, err
+		}
+
+		sigManifests = append(sigManifests, layers...)
+	}
+
+	return sigManifests, nil
+}
+
+// simpleSigningLayers returns the cosign signature payload layers of a signature manifest.
+func simpleSigningLayers(manifestContent []byte) ([]ocispec.Descriptor, error) {
+	var m ocispec.Manifest
+	if err := json.Unmarshal(manifestContent, &m); err != nil {
+		return nil, fmt.Errorf("failed to json decode the signature manifest: %w", err)
+	}
+
+	var layers []ocispec.Descriptor
+	for i, layer := range m.Layers {
+		if layer.MediaType != mediaTypeSimpleSigning || layer.Annotations[annotationCosignSignature] == "" {
+			continue
+		}
+
+		if err := validateDigest(fmt.Sprintf("layers[%d].digest", i), layer); err != nil {
+			return nil, err
+		}
+
+		layers = append(layers, layer)
+	}
+
+	return layers, nil
+}
+
+// cacheKey returns the cache key of the reference in the repository,
+// the string form of the full reference.
+func cacheKey(repoName, reference string) string {
+	if repoName == "" {
+		return ""
+	}
+
+	if strings.Contains(reference, ":") {
+		return repoName + "@" + reference
+	}
+
+	return repoName + ":" + reference
+}
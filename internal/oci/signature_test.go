@@ -0,0 +1,239 @@
+package oci
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// sign signs the payload the way cosign does.
+func sign(t *testing.T, key crypto.Signer, payload []byte) []byte {
+	t.Helper()
+
+	var sig []byte
+	var err error
+
+	switch key.(type) {
+	case *ecdsa.PrivateKey:
+		sum := sha256.Sum256(payload)
+		sig, err = key.Sign(rand.Reader, sum[:], crypto.SHA256)
+	default:
+		sig, err = key.Sign(rand.Reader, payload, crypto.Hash(0))
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return sig
+}
+
+// attachSignature attaches a cosign signature of signed by the key to dgst, with the cosign tag schema.
+func attachSignature(t *testing.T, reg *testRegistry, key crypto.Signer, dgst, signed digest.Digest) {
+	t.Helper()
+
+	var s simpleSigning
+	s.Critical.Type = cosignSignatureType
+	s.Critical.Image.DockerManifestDigest = signed.String()
+
+	payload, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	layer := ocispec.Descriptor{
+		MediaType: mediaTypeSimpleSigning,
+		Digest:    reg.putBlob(payload),
+		Size:      int64(len(payload)),
+		Annotations: map[string]string{
+			annotationCosignSignature: base64.StdEncoding.EncodeToString(sign(t, key, payload)),
+		},
+	}
+
+	config := []byte("{}")
+	m, err := json.Marshal(ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    ocispec.Descriptor{MediaType: "application/vnd.oci.image.config.v1+json", Digest: reg.putBlob(config), Size: 2},
+		Layers:    []ocispec.Descriptor{layer},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reg.putManifest(signatureTag(dgst), ocispec.MediaTypeImageManifest, m)
+}
+
+func TestPullSignature(t *testing.T) {
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		signer  crypto.Signer // nil for unsigned artifacts
+		key     crypto.PublicKey
+		other   bool // whether the signature payload is for another manifest
+		require bool
+		wantErr error
+	}{
+		{name: "ecdsa", signer: ecdsaKey, key: ecdsaKey.Public()},
+		{name: "ed25519", signer: ed25519Key, key: ed25519Key.Public()},
+		{name: "required", signer: ecdsaKey, key: ecdsaKey.Public(), require: true},
+		{name: "other key", signer: otherKey, key: ecdsaKey.Public(), wantErr: ErrInvalidSignature},
+		{name: "other key type", signer: ed25519Key, key: ecdsaKey.Public(), wantErr: ErrInvalidSignature},
+		{name: "other manifest", signer: ecdsaKey, key: ecdsaKey.Public(), other: true, wantErr: ErrInvalidSignature},
+		{name: "unsigned", key: ecdsaKey.Public()},
+		{name: "unsigned required", key: ecdsaKey.Public(), require: true, wantErr: ErrUnsigned},
+		{name: "required without key", require: true, wantErr: ErrMissingSignatureKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			reg, repo := newTestRegistry(t, "v1")
+			image := repo.Reference.String()
+
+			gafPath := filepath.Join(t.TempDir(), LayerGaf)
+			if err := os.WriteFile(gafPath, []byte("gaf"), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			desc, err := Push(ctx, image, []Layer{{Title: LayerGaf, Path: gafPath}}, PushOptions{PlainHTTP: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.signer != nil {
+				signed := desc.Digest
+				if tt.other {
+					signed = digest.FromString("other manifest")
+				}
+
+				attachSignature(t, reg, tt.signer, desc.Digest, signed)
+			}
+
+			dir := t.TempDir()
+			opts := PullOptions{PlainHTTP: true, SignatureKey: tt.key, RequireSignature: tt.require}
+
+			_, err = Pull(ctx, image, dir, opts)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Pull() = %v, want %v", err, tt.wantErr)
+			}
+
+			// Nothing is downloaded from artifacts failing the verification.
+			_, err = os.Stat(filepath.Join(dir, LayerGaf))
+			if pulled := err == nil; pulled != (tt.wantErr == nil) {
+				t.Errorf("%s pulled = %t, want %t", LayerGaf, pulled, tt.wantErr == nil)
+			}
+		})
+	}
+}
+
+func TestPullSignatureIndex(t *testing.T) {
+	ctx := context.Background()
+	reg, repo := newTestRegistry(t, "v1")
+	image := repo.Reference.String()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gafPath := filepath.Join(t.TempDir(), LayerGaf)
+	if err := os.WriteFile(gafPath, []byte("gaf"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	index, err := Push(ctx, image, []Layer{{Title: LayerGaf, Path: gafPath}}, PushOptions{PlainHTTP: true, Arch: "arm64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Signing the image index signs the manifests of all its platforms.
+	attachSignature(t, reg, key, index.Digest, index.Digest)
+
+	opts := PullOptions{PlainHTTP: true, Arch: "arm64", SignatureKey: key.Public(), RequireSignature: true}
+	if _, err := Pull(ctx, image, t.TempDir(), opts); err != nil {
+		t.Errorf("Pull() of a signed image index = %v, want nil", err)
+	}
+}
+
+func TestLoadPublicKey(t *testing.T) {
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ed25519Key, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pemKey := func(key crypto.PublicKey) []byte {
+		b, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return pem.EncodeToMemory(&pem.Block{Type: pemPublicKey, Bytes: b})
+	}
+
+	tests := []struct {
+		name    string
+		content []byte
+		wantErr error
+	}{
+		{name: "ecdsa", content: pemKey(ecdsaKey.Public())},
+		{name: "ed25519", content: pemKey(ed25519Key)},
+		{name: "rsa", content: pemKey(rsaKey.Public()), wantErr: ErrUnsupportedKey},
+		{name: "private key", content: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte{1}}), wantErr: ErrUnsupportedKey},
+		{name: "not pem", content: []byte("cosign.pub"), wantErr: ErrUnsupportedKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := filepath.Join(t.TempDir(), "cosign.pub")
+			if err := os.WriteFile(p, tt.content, 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := LoadPublicKey(p); !errors.Is(err, tt.wantErr) {
+				t.Errorf("LoadPublicKey() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package qemu
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/damdo/gokrazy-machine/internal/disk"
+)
+
+const (
+	// FormatRaw is the qemu format of raw disk images.
+	FormatRaw = "raw"
+
+	// FormatQcow2 is the qemu format of qcow2 disk images.
+	FormatQcow2 = "qcow2"
+
+	imgCmd = "qemu-img"
+)
+
+var ErrOverlayFormat = errors.New("unsupported overlay format")
+
+// Overlay is a copy-on-write overlay of a raw disk image,
+// so that writes to the disk never reach the base image.
+type Overlay struct {
+	// Path is the path of the overlay disk image.
+	Path string
+
+	// Format is the qemu format of the overlay disk image.
+	Format string
+
+	// Base is the path of the base disk image.
+	Base string
+}
+
+// CreateOverlay creates a copy-on-write overlay of the raw base disk image at path.
+// It is a qcow2 image backed by the base one when qemu-img is available,
+// or a sparse raw copy of the base one otherwise.
+func CreateOverlay(ctx context.Context, base, path string) (Overlay, error) {
+	absBase, err := filepath.Abs(base)
+	if err != nil {
+		return Overlay{}, fmt.Errorf("error getting absolute path of %s: %w", base, err)
+	}
+
+	if _, err := exec.LookPath(imgCmd); err == nil {
+		o := Overlay{Path: path + "." + FormatQcow2, Format: FormatQcow2, Base: absBase}
+
+		if err := runImg(ctx, "create", "-q", "-f", FormatQcow2, "-F", FormatRaw, "-b", absBase, o.Path); err != nil {
+			return Overlay{}, fmt.Errorf("error creating qcow2 overlay: %w", err)
+		}
+
+		return o, nil
+	}
+
+	o := Overlay{Path: path + "." + FormatRaw, Format: FormatRaw, Base: absBase}
+
+	if err := sparseCopy(absBase, o.Path); err != nil {
+		return Overlay{}, fmt.Errorf("error creating raw overlay: %w", err)
+	}
+
+	return o, nil
+}
+
+// OpenOverlay returns the overlay of the base disk image previously created at path, if any.
+func OpenOverlay(base, path string) (Overlay, bool) {
+	absBase, err := filepath.Abs(base)
+	if err != nil {
+		return Overlay{}, false
+	}
+
+	for _, format := range []string{FormatQcow2, FormatRaw} {
+		if _, err := os.Stat(path + "." + format); err == nil {
+			return Overlay{Path: path + "." + format, Format: format, Base: absBase}, true
+		}
+	}
+
+	return Overlay{}, false
+}
+
+// Commit writes the changes of the overlay back to the base disk image.
+func (o Overlay) Commit(ctx context.Context) error {
+	switch o.Format {
+	case FormatQcow2:
+		if err := runImg(ctx, "commit", "-q", o.Path); err != nil {
+			return fmt.Errorf("error committing qcow2 overlay: %w", err)
+		}
+
+	case FormatRaw:
+		if err := copyInto(o.Path, o.Base); err != nil {
+			return fmt.Errorf("error committing raw overlay: %w", err)
+		}
+
+	default:
+		return fmt.Errorf("%w: %s", ErrOverlayFormat, o.Format)
+	}
+
+	return nil
+}
+
+func runImg(ctx context.Context, args ...string) error {
+	var stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, imgCmd, args...)
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%v: %w: %s", cmd.Args, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	return nil
+}
+
+// sparseCopy copies the source file to the destination one,
+// leaving holes in place of the zeroed blocks of the source.
+func sparseCopy(sourcePath, destPath string) error {
+	source, err := os.Open(sourcePath)
+	if err != nil {
+		return fmt.Errorf("error opening %s: %w", sourcePath, err)
+	}
+	defer source.Close()
+
+	fi, err := source.Stat()
+	if err != nil {
+		return fmt.Errorf("error getting %s file info: %w", sourcePath, err)
+	}
+
+	dest, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fi.Mode().Perm())
+	if err != nil {
+		return fmt.Errorf("error creating %s: %w", destPath, err)
+	}
+	defer dest.Close()
+
+	if err := dest.Truncate(fi.Size()); err != nil {
+		return fmt.Errorf("error truncating %s: %w", destPath, err)
+	}
+
+	if err := disk.SparseCopy(dest, source); err != nil {
+		return fmt.Errorf("error copying %s to %s: %w", sourcePath, destPath, err)
+	}
+
+	return dest.Close()
+}
+
+// copyInto overwrites the content of the existing destination file with the source file one.
+func copyInto(sourcePath, destPath string) error {
+	source, err := os.Open(sourcePath)
+	if err != nil {
+		return fmt.Errorf("error opening %s: %w", sourcePath, err)
+	}
+	defer source.Close()
+
+	dest, err := os.OpenFile(destPath, os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("error opening %s: %w", destPath, err)
+	}
+	defer dest.Close()
+
+	if _, err := io.Copy(dest, source); err != nil {
+		return fmt.Errorf("error copying %s to %s: %w", sourcePath, destPath, err)
+	}
+
+	return dest.Close()
+}
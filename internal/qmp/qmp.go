@@ -0,0 +1,247 @@
+package qmp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// The QMP commands of the Client helpers.
+const (
+	CommandCapabilities    = "qmp_capabilities"
+	CommandStop            = "stop"
+	CommandCont            = "cont"
+	CommandSystemReset     = "system_reset"
+	CommandSystemPowerdown = "system_powerdown"
+	CommandQueryStatus     = "query-status"
+	CommandQuit            = "quit"
+)
+
+// dialTimeout is the timeout of the connection and handshake with qemu
+// when the context has no deadline.
+const dialTimeout = 5 * time.Second
+
+// ErrProtocol denotes the error for an unexpected message from the QMP server.
+var ErrProtocol = errors.New("unexpected qmp message")
+
+// Error is an error returned by qemu for a QMP command.
+type Error struct {
+	// Class is the error class, e.g. GenericError or CommandNotFound.
+	Class string `json:"class"`
+
+	// Desc is the human readable description of the error.
+	Desc string `json:"desc"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("qmp error %s: %s", e.Class, e.Desc)
+}
+
+// Status is the run state of a machine, as returned by query-status.
+type Status struct {
+	// Running reports whether the vcpus are running.
+	Running bool `json:"running"`
+
+	// Status is the run state, e.g. running, paused or shutdown.
+	Status string `json:"status"`
+}
+
+// Event is an asynchronous QMP event, e.g. STOP or RESUME.
+type Event struct {
+	Event string          `json:"event"`
+	Data  json.RawMessage `json:"data"`
+}
+
+type command struct {
+	Execute   string `json:"execute"`
+	Arguments any    `json:"arguments,omitempty"`
+}
+
+// response is any message of the QMP server: a greeting, a command return or error, or an event.
+type response struct {
+	Greeting *json.RawMessage `json:"QMP"`
+	Return   *json.RawMessage `json:"return"`
+	Error    *Error           `json:"error"`
+	Event    string           `json:"event"`
+	Data     json.RawMessage  `json:"data"`
+}
+
+// Client is a client of the QMP (qemu machine protocol) server of a running qemu.
+// Commands are executed one at a time; the events received in the meantime are kept
+// and can be read with Events.
+type Client struct {
+	mu     sync.Mutex
+	conn   net.Conn
+	r      *bufio.Reader
+	events []Event
+}
+
+// Dial connects to the QMP server listening on the unix socket
+// and negotiates the capabilities, leaving the client in command mode.
+func Dial(ctx context.Context, socket string) (*Client, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, dialTimeout)
+		defer cancel()
+	}
+
+	var d net.Dialer
+
+	conn, err := d.DialContext(ctx, "unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to qmp socket %s: %w", socket, err)
+	}
+
+	c := &Client{conn: conn, r: bufio.NewReader(conn)}
+
+	if err := c.handshake(ctx); err != nil {
+		conn.Close()
+
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *Client) handshake(ctx context.Context) error {
+	c.setDeadline(ctx)
+
+	var greeting response
+	if err := c.read(&greeting); err != nil {
+		return fmt.Errorf("error reading qmp greeting: %w", err)
+	}
+
+	if greeting.Greeting == nil {
+		return fmt.Errorf("%w: expected the qmp greeting", ErrProtocol)
+	}
+
+	return c.Execute(ctx, CommandCapabilities, nil, nil)
+}
+
+// Execute executes the QMP command with the arguments, if not nil,
+// and decodes its return value in result, if not nil.
+func (c *Client) Execute(ctx context.Context, cmd string, args, result any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.setDeadline(ctx)
+
+	b, err := json.Marshal(command{Execute: cmd, Arguments: args})
+	if err != nil {
+		return fmt.Errorf("failed to json encode qmp command %s: %w", cmd, err)
+	}
+
+	if _, err := c.conn.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("error sending qmp command %s: %w", cmd, err)
+	}
+
+	for {
+		var resp response
+		if err := c.read(&resp); err != nil {
+			return fmt.Errorf("error reading qmp command %s response: %w", cmd, err)
+		}
+
+		switch {
+		case resp.Event != "":
+			c.events = append(c.events, Event{Event: resp.Event, Data: resp.Data})
+
+		case resp.Error != nil:
+			return fmt.Errorf("%s: %w", cmd, resp.Error)
+
+		case resp.Return != nil:
+			if result == nil {
+				return nil
+			}
+
+			if err := json.Unmarshal(*resp.Return, result); err != nil {
+				return fmt.Errorf("failed to json decode qmp command %s return: %w", cmd, err)
+			}
+
+			return nil
+
+		default:
+			return fmt.Errorf("%w: in response to %s", ErrProtocol, cmd)
+		}
+	}
+}
+
+// Events returns and forgets the events received so far.
+func (c *Client) Events() []Event {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	events := c.events
+	c.events = nil
+
+	return events
+}
+
+// Stop pauses the machine.
+func (c *Client) Stop(ctx context.Context) error {
+	return c.Execute(ctx, CommandStop, nil, nil)
+}
+
+// Cont resumes the paused machine.
+func (c *Client) Cont(ctx context.Context) error {
+	return c.Execute(ctx, CommandCont, nil, nil)
+}
+
+// SystemReset resets the machine, like pressing its reset button.
+func (c *Client) SystemReset(ctx context.Context) error {
+	return c.Execute(ctx, CommandSystemReset, nil, nil)
+}
+
+// SystemPowerdown asks the guest to power down, like pressing the machine power button.
+func (c *Client) SystemPowerdown(ctx context.Context) error {
+	return c.Execute(ctx, CommandSystemPowerdown, nil, nil)
+}
+
+// Quit terminates qemu immediately, like pulling the machine power cord.
+func (c *Client) Quit(ctx context.Context) error {
+	return c.Execute(ctx, CommandQuit, nil, nil)
+}
+
+// QueryStatus returns the run state of the machine.
+func (c *Client) QueryStatus(ctx context.Context) (Status, error) {
+	var s Status
+	if err := c.Execute(ctx, CommandQueryStatus, nil, &s); err != nil {
+		return Status{}, err
+	}
+
+	return s, nil
+}
+
+// Close closes the connection to the QMP server.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+func (c *Client) read(v *response) error {
+	line, err := c.r.ReadBytes('\n')
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(line, v); err != nil {
+		return fmt.Errorf("%w: %s", ErrProtocol, err)
+	}
+
+	return nil
+}
+
+// setDeadline bounds the next reads and writes by the context deadline, if any.
+func (c *Client) setDeadline(ctx context.Context) {
+	deadline, _ := ctx.Deadline()
+	_ = c.conn.SetDeadline(deadline)
+}
+
+// ServerArgs returns the qemu arguments starting a QMP server on the unix socket,
+// without waiting for a client to connect.
+func ServerArgs(socket string) []string {
+	return []string{"-qmp", "unix:" + socket + ",server=on,wait=off"}
+}
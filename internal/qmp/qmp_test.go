@@ -0,0 +1,240 @@
+package qmp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const testGreeting = `{"QMP": {"version": {"qemu": {"micro": 0, "minor": 2, "major": 8}}, "capabilities": ["oob"]}}`
+
+// testServer is a fake QMP server answering the commands it receives with respond,
+// after sending greeting to its client.
+type testServer struct {
+	socket   string
+	commands chan command
+}
+
+func newTestServer(t *testing.T, greeting string, respond func(command) []string) *testServer {
+	t.Helper()
+
+	socket := filepath.Join(t.TempDir(), "qmp.sock")
+
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	s := &testServer{socket: socket, commands: make(chan command, 100)}
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		if greeting == "" {
+			// Hang until the client gives up.
+			_, _ = conn.Read(make([]byte, 1))
+
+			return
+		}
+
+		fmt.Fprintln(conn, greeting)
+
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			var cmd command
+			if err := json.Unmarshal(scanner.Bytes(), &cmd); err != nil {
+				fmt.Fprintln(conn, `{"error": {"class": "GenericError", "desc": "JSON parse error"}}`)
+
+				continue
+			}
+
+			s.commands <- cmd
+
+			for _, line := range respond(cmd) {
+				fmt.Fprintln(conn, line)
+			}
+		}
+	}()
+
+	return s
+}
+
+// received returns the names and arguments of the commands received so far.
+func (s *testServer) received() []string {
+	var commands []string
+
+	for {
+		select {
+		case cmd := <-s.commands:
+			b, _ := json.Marshal(cmd)
+			commands = append(commands, string(b))
+		default:
+			return commands
+		}
+	}
+}
+
+func respondQemu(cmd command) []string {
+	switch cmd.Execute {
+	case CommandCapabilities, CommandCont, CommandSystemReset, CommandSystemPowerdown, CommandQuit:
+		return []string{`{"return": {}}`}
+	case CommandStop:
+		return []string{
+			`{"timestamp": {"seconds": 1, "microseconds": 2}, "event": "STOP"}`,
+			`{"return": {}}`,
+		}
+	case CommandQueryStatus:
+		return []string{`{"return": {"status": "paused", "singlestep": false, "running": false}}`}
+	case "bogus":
+		return []string{`{"unexpected": true}`}
+	default:
+		return []string{fmt.Sprintf(`{"error": {"class": "CommandNotFound", "desc": "The command %s has not been found"}}`, cmd.Execute)}
+	}
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t, testGreeting, respondQemu)
+
+	c, err := Dial(ctx, s.socket)
+	if err != nil {
+		t.Fatalf("Dial() = %v, want nil", err)
+	}
+	defer c.Close()
+
+	if err := c.Stop(ctx); err != nil {
+		t.Fatalf("Stop() = %v, want nil", err)
+	}
+
+	status, err := c.QueryStatus(ctx)
+	if err != nil {
+		t.Fatalf("QueryStatus() = %v, want nil", err)
+	}
+
+	if want := (Status{Running: false, Status: "paused"}); status != want {
+		t.Errorf("QueryStatus() = %+v, want %+v", status, want)
+	}
+
+	events := c.Events()
+	if len(events) != 1 || events[0].Event != "STOP" {
+		t.Errorf("Events() = %+v, want a STOP event", events)
+	}
+
+	if events := c.Events(); len(events) != 0 {
+		t.Errorf("Events() after reading them = %+v, want none", events)
+	}
+
+	err = c.Execute(ctx, "human-monitor-command", map[string]string{"command-line": "info kvm"}, nil)
+
+	var qmpErr *Error
+	if !errors.As(err, &qmpErr) || qmpErr.Class != "CommandNotFound" {
+		t.Errorf("Execute() of an unknown command = %v, want a CommandNotFound *Error", err)
+	}
+
+	if err := c.Execute(ctx, "bogus", nil, nil); !errors.Is(err, ErrProtocol) {
+		t.Errorf("Execute() with an unexpected response = %v, want %v", err, ErrProtocol)
+	}
+
+	for name, fn := range map[string]func(context.Context) error{
+		CommandCont:            c.Cont,
+		CommandSystemReset:     c.SystemReset,
+		CommandSystemPowerdown: c.SystemPowerdown,
+		CommandQuit:            c.Quit,
+	} {
+		if err := fn(ctx); err != nil {
+			t.Errorf("%s = %v, want nil", name, err)
+		}
+	}
+
+	got := s.received()[:5]
+	want := []string{
+		`{"execute":"qmp_capabilities"}`,
+		`{"execute":"stop"}`,
+		`{"execute":"query-status"}`,
+		`{"execute":"human-monitor-command","arguments":{"command-line":"info kvm"}}`,
+		`{"execute":"bogus"}`,
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("commands received by the server = %q, want %q", got, want)
+	}
+}
+
+func TestDial(t *testing.T) {
+	tests := []struct {
+		name     string
+		greeting string
+		respond  func(command) []string
+		wantErr  error
+	}{
+		{
+			name:     "no greeting",
+			greeting: `{"return": {}}`,
+			respond:  respondQemu,
+			wantErr:  ErrProtocol,
+		},
+		{
+			name:     "malformed greeting",
+			greeting: `QEMU 8.2.0 monitor`,
+			respond:  respondQemu,
+			wantErr:  ErrProtocol,
+		},
+		{
+			name:     "capabilities refused",
+			greeting: testGreeting,
+			respond: func(command) []string {
+				return []string{`{"error": {"class": "CommandNotFound", "desc": "Capabilities negotiation is already complete"}}`}
+			},
+			wantErr: &Error{},
+		},
+		{
+			name:    "hanging server",
+			wantErr: os.ErrDeadlineExceeded,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, tt.greeting, tt.respond)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+
+			c, err := Dial(ctx, s.socket)
+			if err == nil {
+				c.Close()
+			}
+
+			var qmpErr *Error
+			switch want := tt.wantErr.(type) {
+			case *Error:
+				if !errors.As(err, &qmpErr) {
+					t.Errorf("Dial() = %v, want a %T", err, want)
+				}
+			default:
+				if !errors.Is(err, want) {
+					t.Errorf("Dial() = %v, want %v", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDialMissingSocket(t *testing.T) {
+	if _, err := Dial(context.Background(), filepath.Join(t.TempDir(), "qmp.sock")); err == nil {
+		t.Error("Dial() of a missing socket = nil, want an error")
+	}
+}
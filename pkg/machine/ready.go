@@ -0,0 +1,57 @@
+package machine
+
+import (
+	"context"
+	"time"
+
+	"github.com/damdo/gokrazy-machine/internal/machine"
+)
+
+const (
+	// DefaultReadyPath is the HTTP path probed by default, the gokrazy web UI.
+	DefaultReadyPath = machine.DefaultReadyPath
+
+	// DefaultReadyInterval is the default interval between two probes.
+	DefaultReadyInterval = machine.DefaultReadyInterval
+)
+
+var (
+	// ErrNotFound denotes the error for a name without a running machine.
+	ErrNotFound = machine.ErrNotFound
+
+	// ErrNotReady denotes the error for a machine that didn't become ready in time.
+	ErrNotReady = machine.ErrNotReady
+
+	// ErrNoReadyProbe denotes the error for a machine without a forwarded guest port 80 nor a serial marker to probe.
+	ErrNoReadyProbe = machine.ErrNoReadyProbe
+
+	// ErrExited denotes the error for a machine whose qemu exited.
+	ErrExited = machine.ErrExited
+)
+
+// ReadyOptions are the options of WaitReady.
+type ReadyOptions struct {
+	// Path is the HTTP path probed on the host port forwarded to the guest port 80,
+	// DefaultReadyPath if empty.
+	Path string
+
+	// SerialMarker, if not empty, is the text to wait for in the serial console log.
+	SerialMarker string
+
+	// Interval is the interval between two probes, DefaultReadyInterval if zero.
+	Interval time.Duration
+}
+
+// WaitReady waits until the machine with the name, run by gom (e.g. gom play --detach --name <name>),
+// is ready, or the context is done.
+// The machine is ready when the HTTP server forwarded from the guest port 80 answers Path
+// with a success or an authentication challenge (the gokrazy web UI asks for its password),
+// and when SerialMarker, if any, is in its serial console log.
+// The HTTP probe is skipped if the guest port 80 is not forwarded (e.g. with a shared network).
+func WaitReady(ctx context.Context, name string, opts ReadyOptions) error {
+	return machine.WaitReady(ctx, name, machine.ReadyOptions{
+		Path:         opts.Path,
+		SerialMarker: opts.SerialMarker,
+		Interval:     opts.Interval,
+	})
+}